@@ -0,0 +1,282 @@
+package dirhash
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const cacheHeader = "# dirhash cache v1"
+
+// fileStat is metadata of a regular file, which changes if its contents
+// change.
+type fileStat struct {
+	dev   uint64
+	ino   uint64
+	size  int64
+	mtime int64 // ns
+	ctime int64 // ns
+	nlink uint64
+}
+
+type cacheKey struct {
+	dev  uint64
+	ino  uint64
+	algo string
+}
+
+type cacheEntry struct {
+	size    int64
+	mtime   int64
+	ctime   int64
+	written uint64
+	digest  []byte
+}
+
+// Cache maps device, inode, size, mtime and ctime of regular files to
+// message digest, so unchanged files are not re-hashed across walks.
+// Cache is safe for concurrent use.
+type Cache struct {
+	f     string
+	mtx   sync.Mutex
+	m     map[cacheKey]*cacheEntry
+	used  map[cacheKey]bool // looked up or stored since OpenCache
+	rand  *rand.Rand
+	dirty bool
+}
+
+// OpenCache loads cache file f. An empty cache is returned if f does not
+// exist, which is created by Cache.Save.
+func OpenCache(f string) (*Cache, error) {
+	c := &Cache{
+		f:    f,
+		m:    make(map[cacheKey]*cacheEntry),
+		used: make(map[cacheKey]bool),
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	fp, err := os.Open(f)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return c, nil
+		}
+		return nil, err
+	}
+	defer fp.Close()
+
+	scanner := bufio.NewScanner(fp)
+	if !scanner.Scan() || scanner.Text() != cacheHeader {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s is not a cache file", f)
+	}
+	for scanner.Scan() {
+		k, v, err := parseCacheLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		c.m[*k] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func parseCacheLine(s string) (*cacheKey, *cacheEntry, error) {
+	l := strings.Fields(s)
+	if len(l) != 8 {
+		return nil, nil, fmt.Errorf("invalid cache line %q", s)
+	}
+
+	var n [6]int64
+	for i := range n {
+		x, err := strconv.ParseInt(l[i], 10, 64)
+		if err != nil {
+			return nil, nil, err
+		}
+		n[i] = x
+	}
+	b, err := hex.DecodeString(l[7])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	k := &cacheKey{
+		dev:  uint64(n[0]),
+		ino:  uint64(n[1]),
+		algo: l[6],
+	}
+	v := &cacheEntry{
+		size:    n[2],
+		mtime:   n[3],
+		ctime:   n[4],
+		written: uint64(n[5]),
+		digest:  b,
+	}
+	return k, v, nil
+}
+
+// Prune drops entries which have not been looked up or stored since
+// OpenCache, e.g. of removed files, so the cache does not grow with stale
+// entries. Prune should only be called after walking all files cached in c,
+// as entries of files not walked with other inputs or options are dropped.
+func (c *Cache) Prune() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for k := range c.m {
+		if !c.used[k] {
+			delete(c.m, k)
+			c.dirty = true
+		}
+	}
+}
+
+// Save writes c to the cache file if updated.
+func (c *Cache) Save() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if !c.dirty {
+		return nil
+	}
+
+	var l []string
+	for k, v := range c.m {
+		l = append(l, fmt.Sprintf("%d %d %d %d %d %d %s %s",
+			int64(k.dev), int64(k.ino), v.size, v.mtime, v.ctime,
+			v.written, k.algo, getHexSum(v.digest)))
+	}
+	sort.Strings(l)
+
+	// replace cache file at once
+	var b bytes.Buffer
+	b.WriteString(cacheHeader + "\n")
+	for _, s := range l {
+		b.WriteString(s + "\n")
+	}
+	tmp := c.f + ".tmp"
+	if err := os.WriteFile(tmp, b.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.f); err != nil {
+		return err
+	}
+
+	c.dirty = false
+	return nil
+}
+
+func (c *Cache) get(st *fileStat, algo string) *cacheEntry {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	k := cacheKey{st.dev, st.ino, algo}
+	v, ok := c.m[k]
+	if !ok || v.size != st.size || v.mtime != st.mtime || v.ctime != st.ctime {
+		return nil
+	}
+	c.used[k] = true
+	return v
+}
+
+func (c *Cache) put(st *fileStat, algo string, written uint64, b []byte) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	k := cacheKey{st.dev, st.ino, algo}
+	c.m[k] = &cacheEntry{
+		size:    st.size,
+		mtime:   st.mtime,
+		ctime:   st.ctime,
+		written: written,
+		digest:  b,
+	}
+	c.used[k] = true
+	c.dirty = true
+}
+
+// testVerify returns true for fraction p of calls.
+func (c *Cache) testVerify(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.rand.Float64() < p
+}
+
+// hashFile returns hash of f, where hardlinked inode is hashed once per
+// walk.
+func (h *Hasher) hashFile(ctx context.Context, f string) (*fileHash, error) {
+	h.progress.setPath(h.getRealPath(f))
+	defer h.progress.addFile()
+
+	// only regular files whose metadata is available are tracked
+	info, err := h.fs.stat(f)
+	if err != nil {
+		return nil, err
+	}
+	var st *fileStat
+	if info.Mode().IsRegular() {
+		st = getFileStat(info)
+	}
+
+	if st != nil && st.nlink > 1 {
+		return h.links.get(st.getKey(), func() (*fileHash, error) {
+			return h.hashFileCache(ctx, f, st)
+		})
+	}
+	return h.hashFileCache(ctx, f, st)
+}
+
+// hashFileCache returns hash of f using Options.Cache if specified.
+// If digest of cache hit re-hashed by Options.CacheVerify differs,
+// re-hashed digest is returned with mismatch set.
+func (h *Hasher) hashFileCache(ctx context.Context, f string, st *fileStat) (*fileHash, error) {
+	c := h.opt.Cache
+	if c == nil || st == nil {
+		x, err := h.readFileHash(ctx, f)
+		if err != nil {
+			return nil, err
+		}
+		x.st = st
+		return x, nil
+	}
+	now := time.Now()
+
+	// tree hash depends on chunk size
+	algo := h.getHashAlgoLabel()
+
+	v := c.get(st, algo)
+	if v != nil && !c.testVerify(h.opt.CacheVerify) {
+		h.progress.addByte(v.written)
+		return &fileHash{written: v.written, b: v.digest, st: st}, nil
+	}
+
+	x, err := h.readFileHash(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	x.st = st
+	x.mismatch = v != nil && !bytes.Equal(v.digest, x.b)
+
+	// file may be modified after stat within timestamp granularity
+	if now.UnixNano()-st.ctime > int64(time.Second) {
+		c.put(st, algo, x.written, x.b)
+	}
+
+	return x, nil
+}
@@ -0,0 +1,140 @@
+package dirhash
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_OpenCache(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "cache")
+	c, err := OpenCache(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.m) != 0 {
+		t.Error(c.m)
+	}
+
+	// nothing to save
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := pathExists(hostFS{}, f); exists {
+		t.Error(f)
+	}
+
+	st := &fileStat{dev: 1, ino: 2, size: 3, mtime: 4, ctime: 5}
+	_, b, _ := getStringHash("xxx", SHA256)
+	c.put(st, SHA256, 3, b)
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = OpenCache(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := c.get(st, SHA256); v == nil || v.written != 3 || !bytes.Equal(v.digest, b) {
+		t.Error(v)
+	}
+	if v := c.get(st, SHA1); v != nil {
+		t.Error(v)
+	}
+	x := *st
+	x.mtime++
+	if v := c.get(&x, SHA256); v != nil {
+		t.Error(v)
+	}
+
+	// entries not looked up are dropped
+	y := &fileStat{dev: 1, ino: 3, size: 3, mtime: 4, ctime: 5}
+	c.put(y, SHA256, 3, b)
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	c, err = OpenCache(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := c.get(y, SHA256); v == nil {
+		t.Error(v)
+	}
+	c.Prune()
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	c, err = OpenCache(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.m) != 1 || c.get(y, SHA256) == nil {
+		t.Error(c.m)
+	}
+
+	if err := os.WriteFile(f, []byte("xxx\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenCache(f); err == nil {
+		t.Error(f)
+	}
+}
+
+func Test_hashFile(t *testing.T) {
+	d := t.TempDir()
+	f := filepath.Join(d, "x")
+	if err := os.WriteFile(f, []byte("xxx"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := getFileStat(info)
+	if st == nil {
+		t.Skip("file stat unavailable")
+	}
+
+	c, err := OpenCache(filepath.Join(d, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, b, _ := getStringHash("yyy", SHA256)
+	c.put(st, SHA256, 3, b)
+
+	// cache hit is trusted
+	h, err := NewHasher(Options{HashAlgo: SHA256, Cache: c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := h.hashFile(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x.written != 3 || !bytes.Equal(x.b, b) || x.mismatch {
+		t.Error(x)
+	}
+
+	// cache hit is re-hashed
+	h, err = NewHasher(Options{HashAlgo: SHA256, Cache: c, CacheVerify: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := h.HashInput(context.Background(), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Stat.CacheMismatch) != 1 || res.Stat.CacheMismatch[0] != "x" {
+		t.Error(res.Stat.CacheMismatch)
+	}
+	_, b, _ = getStringHash("xxx", SHA256)
+	if res.Lines[0] != getHexSum(b)+"  x" {
+		t.Error(res.Lines)
+	}
+
+	if _, err := NewHasher(Options{HashAlgo: SHA256, CacheVerify: 1.5}); err == nil {
+		t.Error("invalid cache verify")
+	}
+}
@@ -0,0 +1,338 @@
+package dirhash
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListEntry is a parsed line of dirhash output.
+type ListEntry struct {
+	Path          string // "." if squash line of input itself
+	HexSum        string
+	HashAlgo      string // set if tagged
+	Squash        bool
+	SquashLabel   string
+	SquashVersion int
+}
+
+type CheckStatus int
+
+const (
+	CheckOK CheckStatus = iota
+	CheckFailed
+	CheckMissing
+)
+
+func (s CheckStatus) String() string {
+	switch s {
+	case CheckOK:
+		return "OK"
+	case CheckFailed:
+		return "FAILED"
+	case CheckMissing:
+		return "MISSING"
+	default:
+		panic(fmt.Sprintf("unknown check status %d", s))
+	}
+}
+
+type CheckEntry struct {
+	Path   string
+	Status CheckStatus
+	Err    error // error on hashing if any
+}
+
+type CheckResult struct {
+	Entries []*CheckEntry
+	Invalid uint // number of improperly formatted lines
+}
+
+func (r *CheckResult) NumStatus(s CheckStatus) uint {
+	n := uint(0)
+	for _, e := range r.Entries {
+		if e.Status == s {
+			n++
+		}
+	}
+	return n
+}
+
+var (
+	squashSuffixRegexp = regexp.MustCompile(`\[([a-z]+)\]\[v([0-9]+)\]$`)
+	tagRegexp          = regexp.MustCompile(`^([A-Z0-9/-]+) \((.*)\) = ([0-9A-Fa-f]+)$`)
+)
+
+// ParseLine parses a line printed by dirhash.
+// Layout of swap is tried first if swap is set.
+func ParseLine(s string, swap bool) (*ListEntry, error) {
+	// file path is escaped if line starts with \
+	if strings.HasPrefix(s, "\\") {
+		e, err := parseRawLine(s[1:], swap)
+		if err != nil {
+			return nil, err
+		}
+		e.Path = unescapePath(e.Path)
+		return e, nil
+	}
+	return parseRawLine(s, swap)
+}
+
+// parseLine parses a line printed by h, whose file path is never escaped if
+// Options.Zero.
+func (h *Hasher) parseLine(s string) (*ListEntry, error) {
+	if h.opt.Zero {
+		return parseRawLine(s, h.opt.Swap)
+	}
+	return ParseLine(s, h.opt.Swap)
+}
+
+func parseRawLine(s string, swap bool) (*ListEntry, error) {
+	e := &ListEntry{}
+	if m := squashSuffixRegexp.FindStringSubmatchIndex(s); m != nil {
+		e.Squash = true
+		e.SquashLabel = s[m[2]:m[3]]
+		v, err := strconv.Atoi(s[m[4]:m[5]])
+		if err != nil {
+			return nil, err
+		}
+		e.SquashVersion = v
+		s = s[:m[0]]
+	}
+
+	// tagged line
+	if m := tagRegexp.FindStringSubmatch(s); m != nil {
+		if algo := getHashAlgoFromTag(m[1]); len(algo) != 0 {
+			if x, valid := IsValidHexSum(m[3]); valid && len(m[2]) != 0 {
+				e.Path = m[2]
+				e.HexSum = strings.ToLower(x)
+				e.HashAlgo = algo
+				return e, nil
+			}
+		}
+	}
+
+	// squash line of input itself has no path
+	if !strings.Contains(s, "  ") {
+		if !e.Squash {
+			return nil, fmt.Errorf("no file path in %q", s)
+		}
+		if x, valid := IsValidHexSum(s); !valid {
+			return nil, fmt.Errorf("invalid hex sum in %q", s)
+		} else {
+			e.Path = "."
+			e.HexSum = strings.ToLower(x)
+			return e, nil
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		var f, x string
+		if (i == 0) == !swap {
+			n := strings.Index(s, "  ")
+			x, f = s[:n], s[n+2:]
+		} else {
+			n := strings.LastIndex(s, "  ")
+			f, x = s[:n], s[n+2:]
+		}
+		if x, valid := IsValidHexSum(x); valid && len(f) != 0 {
+			e.Path = f
+			e.HexSum = strings.ToLower(x)
+			return e, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid line %q", s)
+}
+
+// newLineScanner returns a scanner of lines printed by h, which are NUL
+// terminated if Options.Zero.
+func (h *Hasher) newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	if h.opt.Zero {
+		scanner.Split(scanZero)
+	}
+	return scanner
+}
+
+// scanZero is a bufio.SplitFunc which splits data by NUL.
+func scanZero(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// CheckInput verifies each entry listed in r, which is output of dirhash
+// against input f, by re-hashing listed paths relative to f.
+func (h *Hasher) CheckInput(ctx context.Context, f string, r io.Reader) (*CheckResult, error) {
+	f, err := h.initInput(f)
+	if err != nil {
+		return nil, err
+	} else if len(f) == 0 {
+		return nil, errors.New("no input to check")
+	}
+	prefix := h.inputPrefix
+
+	res := &CheckResult{}
+	scanner := h.newLineScanner(r)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		s := scanner.Text()
+		if len(s) == 0 {
+			continue
+		}
+		e, err := h.parseLine(s)
+		if err != nil {
+			res.Invalid++
+			continue
+		}
+
+		var c *CheckEntry
+		if len(e.HashAlgo) != 0 && e.HashAlgo != h.getHashAlgoLabel() {
+			c = &CheckEntry{Path: e.Path, Status: CheckFailed,
+				Err: fmt.Errorf("hash algorithm %s mismatch", e.HashAlgo)}
+		} else if e.Squash {
+			c, err = h.checkSquash(ctx, prefix, f, e)
+		} else {
+			c, err = h.checkFile(ctx, prefix, e)
+		}
+		if err != nil {
+			return res, err
+		}
+		res.Entries = append(res.Entries, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+func (h *Hasher) checkSquash(ctx context.Context, prefix string, f string, e *ListEntry) (*CheckEntry, error) {
+	c := &CheckEntry{Path: e.Path}
+	if e.SquashLabel != squashLabel || e.SquashVersion != squashVersion {
+		c.Status = CheckFailed
+		c.Err = fmt.Errorf("[%s][v%d] not supported",
+			e.SquashLabel, e.SquashVersion)
+		return c, nil
+	}
+
+	// squash line of input itself has no path
+	if e.Path != "." {
+		f = getCheckPath(prefix, e.Path)
+	}
+	if exists, _ := pathExists(h.fs, f); !exists {
+		c.Status = CheckMissing
+		return c, nil
+	}
+
+	// verify file contents, not cache
+	x := h.newSubHasher()
+	x.opt.Squash = true
+	x.opt.Cache = nil
+	res, err := x.WalkInput(ctx, f, func(*Entry) error { return nil })
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		c.Status = CheckFailed
+		c.Err = err
+	} else if getHexSum(res.Squash) == e.HexSum {
+		c.Status = CheckOK
+	} else {
+		c.Status = CheckFailed
+	}
+
+	return c, nil
+}
+
+func (h *Hasher) checkFile(ctx context.Context, prefix string, e *ListEntry) (*CheckEntry, error) {
+	c := &CheckEntry{Path: e.Path}
+
+	// followed symlink is printed in link -> target format
+	f, follow := e.Path, false
+	if _, err := h.fs.lstat(getCheckPath(prefix, f)); err != nil {
+		if l := strings.SplitN(f, " -> ", 2); len(l) == 2 {
+			f, follow = l[0], true
+		}
+	}
+	f = getCheckPath(prefix, f)
+	if _, err := h.fs.lstat(f); err != nil {
+		c.Status = CheckMissing
+		return c, nil
+	}
+
+	// listed paths are hashed regardless of ignore options, patterns, ignore
+	// files and filters, and file contents are verified, not cache
+	x := h.newSubHasher()
+	x.inputPrefix = prefix
+	x.opt.Cache = nil
+	x.opt.IgnoreDot = false
+	x.opt.IgnoreDotDir = false
+	x.opt.IgnoreDotFile = false
+	x.opt.IgnoreSymlink = false
+	x.opt.GitIgnore = false
+	x.opt.Type = nil
+	x.opt.MinSize = 0
+	x.opt.MaxSize = 0
+	x.opt.Newer = time.Time{}
+	x.opt.Older = time.Time{}
+	x.opt.Include = nil
+	x.opt.Exclude = nil
+	x.include = nil
+	x.exclude = nil
+	x.ignores = nil
+	x.opt.FollowSymlink = follow
+	x.opt.Abs = filepath.IsAbs(e.Path)
+	var v *Entry
+	x.fn = func(e *Entry) error {
+		v = e
+		return nil
+	}
+	if err := x.walkDirectoryImpl(ctx, f); err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		c.Status = CheckFailed
+		c.Err = err
+		return c, nil
+	}
+
+	if v == nil {
+		c.Status = CheckFailed
+		c.Err = fmt.Errorf("%s not hashed", e.Path)
+	} else if v.getPath() == e.Path && getHexSum(v.Digest) == e.HexSum {
+		c.Status = CheckOK
+	} else {
+		c.Status = CheckFailed
+	}
+
+	return c, nil
+}
+
+func getCheckPath(prefix string, f string) string {
+	if filepath.IsAbs(f) {
+		return path.Clean(f)
+	} else {
+		return path.Join(prefix, f)
+	}
+}
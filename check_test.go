@@ -0,0 +1,210 @@
+package dirhash
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ParseLine(t *testing.T) {
+	x := strings.Repeat("0", 64)
+	lineList := []struct {
+		s      string
+		swap   bool
+		path   string
+		squash bool
+	}{
+		{x + "  a", false, "a", false},
+		{x + "  a", true, "a", false},
+		{"a  " + x, false, "a", false},
+		{"a  " + x, true, "a", false},
+		{x + "  a  b", false, "a  b", false},
+		{"a  b  " + x, true, "a  b", false},
+		{x + "  l -> a", false, "l -> a", false},
+		{x + "[squash][v2]", false, ".", true},
+		{x + "  a[squash][v1]", false, "a", true},
+		{"a  " + x + "[squash][v1]", true, "a", true},
+		{"0x" + x + "  a", false, "a", false},
+		{"SHA256 (a b) = " + x, false, "a b", false},
+		{"SHA256 (a) b) = " + x, true, "a) b", false},
+		{"\\" + x + "  a\\nb\\\\c", false, "a\nb\\c", false},
+		{"\\SHA256 (a\\rb) = " + x, false, "a\rb", false},
+	}
+	for _, v := range lineList {
+		e, err := ParseLine(v.s, v.swap)
+		if err != nil {
+			t.Error(v, err)
+			continue
+		}
+		if e.Path != v.path || e.HexSum != x || e.Squash != v.squash {
+			t.Error(v, e)
+		}
+	}
+
+	invalidList := []string{
+		"",
+		x,
+		"a  b",
+		x + "  ",
+		"1 unsupported file",
+		"fifo (unsupported file)",
+	}
+	for _, s := range invalidList {
+		if e, err := ParseLine(s, false); err == nil {
+			t.Error(s, e)
+		}
+	}
+}
+
+func Test_CheckInput(t *testing.T) {
+	optList := []Options{
+		{HashAlgo: SHA256},
+		{HashAlgo: SHA256, Swap: true},
+		{HashAlgo: SHA256, Abs: true},
+		{HashAlgo: SHA256, FollowSymlink: true},
+		{HashAlgo: SHA256, Squash: true},
+		{HashAlgo: SHA256, Squash: true, FollowSymlink: true},
+		{HashAlgo: SHA256, Zero: true},
+	}
+	for _, opt := range optList {
+		d := newTestTree(t)
+		h, err := NewHasher(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := h.HashInput(context.Background(), d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sep := "\n"
+		if opt.Zero {
+			sep = "\x00"
+		}
+		s := strings.Join(res.Lines, sep)
+
+		ret, err := h.CheckInput(context.Background(), d, strings.NewReader(s))
+		if err != nil {
+			t.Error(opt, err)
+			continue
+		}
+		if n := ret.NumStatus(CheckOK); n == 0 || n != uint(len(ret.Entries)) {
+			t.Error(opt, ret.Entries)
+		}
+
+		// modify and remove files
+		if err := os.WriteFile(filepath.Join(d, "a/x"), []byte("xxx"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Remove(filepath.Join(d, "z")); err != nil {
+			t.Fatal(err)
+		}
+		ret, err = h.CheckInput(context.Background(), d, strings.NewReader(s))
+		if err != nil {
+			t.Error(opt, err)
+			continue
+		}
+		if opt.Squash {
+			if ret.NumStatus(CheckFailed) != 1 {
+				t.Error(opt, ret.Entries)
+			}
+			continue
+		}
+		m := make(map[string]CheckStatus)
+		for _, e := range ret.Entries {
+			m[e.Path] = e.Status
+		}
+		prefix := ""
+		if opt.Abs {
+			prefix = d + "/"
+		}
+		checkList := []struct {
+			path   string
+			status CheckStatus
+		}{
+			{prefix + "a/x", CheckFailed},
+			{prefix + "a/b/y", CheckOK},
+			{prefix + "z", CheckMissing},
+		}
+		for _, v := range checkList {
+			if m[v.path] != v.status {
+				t.Error(opt, v, fmt.Sprint(m))
+			}
+		}
+	}
+}
+
+func Test_CheckInputIgnored(t *testing.T) {
+	d := newTestTree(t)
+	if err := os.WriteFile(filepath.Join(d, ".dirhashignore"), []byte("z\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHasher(Options{HashAlgo: SHA256, Sort: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := h.HashInput(context.Background(), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Join(res.Lines, "\n")
+
+	// listed files are verified even if ignored
+	optList := []Options{
+		{Exclude: []string{"a"}},
+		{Include: []string{"z"}},
+		{GitIgnore: true},
+		{Type: []FileType{TypeDir}},
+		{MinSize: 100},
+		{Newer: time.Now().Add(time.Hour)},
+	}
+	for _, opt := range optList {
+		opt.HashAlgo = SHA256
+		h, err := NewHasher(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ret, err := h.CheckInput(context.Background(), d, strings.NewReader(s))
+		if err != nil {
+			t.Fatal(opt, err)
+		}
+		if n := ret.NumStatus(CheckOK); n != 5 || n != uint(len(ret.Entries)) {
+			t.Error(opt, ret.Entries)
+		}
+	}
+}
+
+func Test_CheckInputChunkSize(t *testing.T) {
+	d := newTestTree(t)
+	h, err := NewHasher(Options{HashAlgo: SHA256, ChunkSize: 4096, Tag: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := h.HashInput(context.Background(), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Join(res.Lines, "\n")
+
+	// tree hash of other chunk size is algorithm mismatch
+	h, err = NewHasher(Options{HashAlgo: SHA256, ChunkSize: 8192})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err := h.CheckInput(context.Background(), d, strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range ret.Entries {
+		if e.Status != CheckFailed || e.Err == nil ||
+			!strings.Contains(e.Err.Error(), "mismatch") {
+			t.Error(e)
+		}
+	}
+	if len(ret.Entries) == 0 {
+		t.Error(ret.Entries)
+	}
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/kusumi/dirhash"
+)
+
+var (
+	version [3]int = [3]int{0, 4, 5}
+)
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+func getVersionString() string {
+	return fmt.Sprintf("%d.%d.%d", version[0], version[1], version[2])
+}
+
+func printVersion() {
+	fmt.Println(getVersionString())
+}
+
+func usage(progname string) {
+	fmt.Fprintln(os.Stderr, "usage: "+progname+": [<options>] <paths>")
+	fmt.Fprintln(os.Stderr, "       "+progname+": diff [<options>] <path1> <path2>")
+	flag.PrintDefaults()
+}
+
+func main() {
+	progname := path.Base(os.Args[0])
+
+	optHashAlgoAddr := flag.String("hash_algo", dirhash.SHA256, "Hash algorithm to use")
+	optHashVerifyAddr := flag.String("hash_verify", "", "Message digest to verify in hex string")
+	optHashOnlyAddr := flag.Bool("hash_only", false, "Do not print file paths")
+	optIgnoreDotAddr := flag.Bool("ignore_dot", false, "Ignore entries start with .")
+	optIgnoreDotDirAddr := flag.Bool("ignore_dot_dir", false, "Ignore directories start with .")
+	optIgnoreDotFileAddr := flag.Bool("ignore_dot_file", false, "Ignore files start with .")
+	optIgnoreSymlinkAddr := flag.Bool("ignore_symlink", false, "Ignore symbolic links")
+	optGitIgnoreAddr := flag.Bool("gitignore", false, "Ignore files by .gitignore and .dirhashignore")
+	var optInclude, optExclude stringList
+	flag.Var(&optInclude, "include", "Ignore files unless relative path matches glob pattern (repeatable)")
+	flag.Var(&optExclude, "exclude", "Ignore files whose relative path matches glob pattern (repeatable)")
+	optExcludeFromAddr := flag.String("exclude_from", "", "Read exclude patterns from file (- for stdin)")
+	optTypeAddr := flag.String("type", "", "Ignore entries unless of comma separated types (dir, reg, device, symlink)")
+	optMinSizeAddr := flag.Int64("min_size", 0, "Ignore regular files smaller than specified bytes")
+	optMaxSizeAddr := flag.Int64("max_size", 0, "Ignore regular files larger than specified bytes if > 0")
+	optNewerAddr := flag.String("newer", "", "Ignore entries not modified after time (e.g. 2006-01-02T15:04:05Z07:00) or file")
+	optOlderAddr := flag.String("older", "", "Ignore entries not modified before time or file")
+	optMinDepthAddr := flag.Int("mindepth", 0, "Ignore entries less than specified levels below input")
+	optMaxDepthAddr := flag.Int("maxdepth", 0, "Walk entries up to specified levels below input if > 0")
+	optXdevAddr := flag.Bool("xdev", false, "Do not walk directories on other filesystems")
+	optFollowSymlinkAddr := flag.Bool("follow_symlink", false, "Follow symbolic links unless directory")
+	optFollowDirSymlinkAddr := flag.Bool("follow_dir_symlink", false, "Walk into symbolic links to directories, except for loops")
+	optSymlinkHashAddr := flag.String("symlink_hash", "", "Hash symlinks by name, target or target_rel (default of squash version if empty)")
+	optAbsAddr := flag.Bool("abs", false, "Print file paths in absolute path")
+	optSwapAddr := flag.Bool("swap", false, "Print file path first in each line")
+	optTagAddr := flag.Bool("tag", false, "Print BSD style tagged output")
+	optZeroAddr := flag.Bool("z", false, "End each output line with NUL instead of newline")
+	optFiles0FromAddr := flag.String("files0_from", "", "Read NUL terminated input paths from file (- for stdin)")
+	optSortAddr := flag.Bool("sort", false, "Print sorted file paths")
+	optJobsAddr := flag.Int("jobs", 1, "Number of files to hash concurrently")
+	optCacheAddr := flag.String("cache", "", "Reuse message digest of unchanged files stored in cache file")
+	optCacheVerifyAddr := flag.Float64("cache_verify", 0, "Fraction of cached files to re-hash (0 to 1)")
+	optCachePruneAddr := flag.Bool("cache_prune", false, "Drop cache of files not walked, e.g. removed ones")
+	optChunkSizeAddr := flag.Int64("chunk_size", 0, "Tree hash files in chunks of specified bytes concurrently")
+	optBwLimitAddr := flag.Int64("bwlimit", 0, "Limit bytes read from files per second")
+	optReportHardlinkAddr := flag.Bool("report_hardlink", false, "Print hardlinked files")
+	optSquashHardlinkOnceAddr := flag.Bool("squash_hardlink_once", false, "Squash hardlinked file once instead of per link")
+	optProgressAddr := flag.Bool("progress", false, "Print progress to stderr")
+	optProgressScanAddr := flag.Bool("progress_scan", false, "Scan file sizes before walk to print ETA with -progress")
+	optFormatAddr := flag.String("format", dirhash.FormatText, "Output format (text, json, ndjson)")
+	optSquashAddr := flag.Bool("squash", false, "Print squashed message digest instead of per file")
+	optCheckAddr := flag.String("check", "", "Verify dirhash output read from file (- for stdin) against input")
+	optDriftAddr := flag.String("drift", "", "Report drift of input from dirhash output read from file (- for stdin)")
+	optVerboseAddr := flag.Bool("verbose", false, "Enable verbose print")
+	optTimeoutAddr := flag.Duration("timeout", 0, "Stop walk after specified duration (e.g. 10m)")
+	optDebugAddr := flag.Bool("debug", false, "Enable debug print")
+	optVersionAddr := flag.Bool("v", false, "Print version and exit")
+	optHelpAddr := flag.Bool("h", false, "Print usage and exit")
+
+	// diff subcommand takes the same options
+	diff := len(os.Args) > 1 && os.Args[1] == "diff"
+	if diff {
+		_ = flag.CommandLine.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
+	args := flag.Args()
+	opt := dirhash.Options{
+		HashAlgo:           *optHashAlgoAddr,
+		HashVerify:         *optHashVerifyAddr,
+		HashOnly:           *optHashOnlyAddr,
+		IgnoreDot:          *optIgnoreDotAddr,
+		IgnoreDotDir:       *optIgnoreDotDirAddr,
+		IgnoreDotFile:      *optIgnoreDotFileAddr,
+		IgnoreSymlink:      *optIgnoreSymlinkAddr,
+		GitIgnore:          *optGitIgnoreAddr,
+		Include:            optInclude,
+		Exclude:            optExclude,
+		MinSize:            *optMinSizeAddr,
+		MaxSize:            *optMaxSizeAddr,
+		MinDepth:           *optMinDepthAddr,
+		MaxDepth:           *optMaxDepthAddr,
+		Xdev:               *optXdevAddr,
+		FollowSymlink:      *optFollowSymlinkAddr,
+		FollowDirSymlink:   *optFollowDirSymlinkAddr,
+		SymlinkHash:        *optSymlinkHashAddr,
+		Abs:                *optAbsAddr,
+		Swap:               *optSwapAddr,
+		Tag:                *optTagAddr,
+		Zero:               *optZeroAddr,
+		Sort:               *optSortAddr,
+		Jobs:               *optJobsAddr,
+		CacheVerify:        *optCacheVerifyAddr,
+		ChunkSize:          *optChunkSizeAddr,
+		BwLimit:            *optBwLimitAddr,
+		ReportHardlink:     *optReportHardlinkAddr,
+		SquashHardlinkOnce: *optSquashHardlinkOnceAddr,
+		ProgressScan:       *optProgressScanAddr,
+		Squash:             *optSquashAddr,
+		Verbose:            *optVerboseAddr,
+		Debug:              *optDebugAddr,
+		Format:             *optFormatAddr,
+	}
+
+	if *optProgressAddr {
+		opt.Progress = os.Stderr
+	}
+
+	if *optVersionAddr {
+		printVersion()
+		os.Exit(1)
+	}
+
+	if *optHelpAddr {
+		usage(progname)
+		os.Exit(1)
+	}
+
+	if len(*optTypeAddr) != 0 {
+		l, err := parseFileTypeList(*optTypeAddr)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		opt.Type = l
+	}
+
+	for _, x := range []struct {
+		s string
+		t *time.Time
+	}{
+		{*optNewerAddr, &opt.Newer},
+		{*optOlderAddr, &opt.Older},
+	} {
+		if len(x.s) != 0 {
+			t, err := parseTimeOrFile(x.s)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			*x.t = t
+		}
+	}
+
+	if len(*optExcludeFromAddr) != 0 {
+		l, err := readExcludeFrom(*optExcludeFromAddr)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		opt.Exclude = append(opt.Exclude, l...)
+	}
+
+	if len(*optFiles0FromAddr) != 0 {
+		if len(args) != 0 {
+			fmt.Println("-files0_from can not be combined with input paths")
+			os.Exit(1)
+		}
+		l, err := readFiles0From(*optFiles0FromAddr)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		args = l
+	}
+
+	if len(args) < 1 {
+		usage(progname)
+		os.Exit(1)
+	}
+
+	if len(*optCacheAddr) != 0 {
+		c, err := dirhash.OpenCache(*optCacheAddr)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		opt.Cache = c
+	}
+
+	h, err := dirhash.NewHasher(opt)
+	if err != nil {
+		fmt.Println(err)
+		if s := strings.ToLower(opt.HashAlgo); len(s) != 0 && dirhash.NewHash(s) == nil {
+			fmt.Println("Available hash algorithm", dirhash.GetAvailableHashAlgo())
+		}
+		os.Exit(1)
+	}
+	opt = h.Options()
+	if opt.Verbose && opt.Format == dirhash.FormatText {
+		fmt.Println(h.HashAlgoLabel())
+	}
+
+	// save cache on exit, including interrupted walk
+	exit := func(code int) {
+		if opt.Cache != nil {
+			if err := opt.Cache.Save(); err != nil {
+				fmt.Println(err)
+				code = 1
+			}
+		}
+		os.Exit(code)
+	}
+
+	// stop walk on SIGINT or timeout
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// second SIGINT kills process, e.g. stuck in read
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	if *optTimeoutAddr > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *optTimeoutAddr)
+		defer cancel()
+	}
+
+	if diff {
+		if len(args) != 2 {
+			usage(progname)
+			exit(1)
+		}
+		if same, err := diffInput(ctx, h, args[0], args[1]); err != nil {
+			fmt.Println(err)
+			exit(1)
+		} else if !same {
+			exit(1)
+		}
+		exit(0)
+	}
+
+	if len(*optDriftAddr) != 0 {
+		if len(args) != 1 {
+			fmt.Println("-drift requires single input")
+			exit(1)
+		}
+		if same, err := driftInput(ctx, h, *optDriftAddr, args[0]); err != nil {
+			fmt.Println(err)
+			exit(1)
+		} else if !same {
+			exit(1)
+		}
+		exit(0)
+	}
+
+	if len(*optCheckAddr) != 0 {
+		if len(args) != 1 {
+			fmt.Println("-check requires single input")
+			exit(1)
+		}
+		if ok, err := checkInput(ctx, h, *optCheckAddr, args[0]); err != nil {
+			fmt.Println(err)
+			exit(1)
+		} else if !ok {
+			exit(1)
+		}
+		exit(0)
+	}
+
+	// json output of each input is a document
+	if opt.Format == dirhash.FormatJSON && len(args) > 1 {
+		fmt.Println("-format json requires single input, use ndjson for multiple inputs")
+		exit(1)
+	}
+
+	// cache mismatch means cached digest is no longer valid
+	code := 0
+	for i, x := range args {
+		res, err := h.PrintInput(ctx, os.Stdout, x)
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+		if res.Stat != nil && len(res.Stat.CacheMismatch) > 0 {
+			code = 1
+		}
+		if opt.Verbose && opt.Format == dirhash.FormatText && len(args) > 0 && i != len(args)-1 {
+			fmt.Println()
+		}
+	}
+
+	// drop cache of files not walked if specified, as the cache may be
+	// shared with other inputs or options
+	if opt.Cache != nil && *optCachePruneAddr {
+		opt.Cache.Prune()
+	}
+	exit(code)
+}
+
+func openInput(f string) (*os.File, error) {
+	if f == "-" {
+		return os.Stdin, nil
+	}
+	return os.Open(f)
+}
+
+func readFiles0From(f string) ([]string, error) {
+	r, err := openInput(f)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	s := strings.TrimSuffix(string(b), "\x00")
+	if len(s) == 0 {
+		return nil, nil
+	}
+
+	l := strings.Split(s, "\x00")
+	for _, x := range l {
+		if len(x) == 0 {
+			return nil, fmt.Errorf("invalid zero-length file name in %s", f)
+		}
+	}
+	return l, nil
+}
+
+func parseFileTypeList(s string) ([]dirhash.FileType, error) {
+	var l []dirhash.FileType
+	for _, x := range strings.Split(s, ",") {
+		switch x {
+		case "dir":
+			l = append(l, dirhash.TypeDir)
+		case "reg":
+			l = append(l, dirhash.TypeReg)
+		case "device":
+			l = append(l, dirhash.TypeDevice)
+		case "symlink":
+			l = append(l, dirhash.TypeSymlink)
+		default:
+			return nil, fmt.Errorf("invalid type %s", x)
+		}
+	}
+	return l, nil
+}
+
+// parseTimeOrFile returns time s in RFC 3339 or its prefix in local time,
+// or modification time of file s.
+func parseTimeOrFile(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	info, err := os.Stat(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time or file %s", s)
+	}
+	return info.ModTime(), nil
+}
+
+// readExcludeFrom reads a pattern per line, where empty lines and lines
+// start with # are skipped.
+func readExcludeFrom(f string) ([]string, error) {
+	r, err := openInput(f)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var l []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		s := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(s) == 0 || strings.HasPrefix(s, "#") {
+			continue
+		}
+		l = append(l, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func checkInput(ctx context.Context, h *dirhash.Hasher, f string, input string) (bool, error) {
+	r, err := openInput(f)
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+
+	res, err := h.CheckInput(ctx, input, r)
+	if res != nil {
+		for _, e := range res.Entries {
+			if e.Err != nil {
+				fmt.Printf("%s: %s (%s)\n", dirhash.EscapePath(e.Path), e.Status, e.Err)
+			} else {
+				fmt.Printf("%s: %s\n", dirhash.EscapePath(e.Path), e.Status)
+			}
+		}
+		fmt.Printf("%d OK, %d FAILED, %d MISSING\n",
+			res.NumStatus(dirhash.CheckOK),
+			res.NumStatus(dirhash.CheckFailed),
+			res.NumStatus(dirhash.CheckMissing))
+		if h.Options().Verbose && res.Invalid > 0 {
+			fmt.Printf("%d improperly formatted line(s)\n", res.Invalid)
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return len(res.Entries) > 0 &&
+		res.NumStatus(dirhash.CheckOK) == uint(len(res.Entries)), nil
+}
+
+func diffInput(ctx context.Context, h *dirhash.Hasher, f1 string, f2 string) (bool, error) {
+	res, err := h.DiffInput(ctx, f1, f2)
+	if err != nil {
+		return false, err
+	}
+
+	for _, e := range res.Entries {
+		if e.Status == dirhash.DiffTypeChanged {
+			fmt.Printf("%s: %s (%s -> %s)\n", dirhash.EscapePath(e.Path), e.Status,
+				e.Old.GetTypeString(), e.New.GetTypeString())
+		} else {
+			fmt.Printf("%s: %s\n", dirhash.EscapePath(e.Path), e.Status)
+		}
+	}
+	if h.Options().Verbose {
+		fmt.Printf("%d ADDED, %d REMOVED, %d MODIFIED, %d TYPE CHANGED\n",
+			res.NumStatus(dirhash.DiffAdded),
+			res.NumStatus(dirhash.DiffRemoved),
+			res.NumStatus(dirhash.DiffModified),
+			res.NumStatus(dirhash.DiffTypeChanged))
+	}
+
+	return len(res.Entries) == 0, nil
+}
+
+func driftInput(ctx context.Context, h *dirhash.Hasher, f string, input string) (bool, error) {
+	r, err := openInput(f)
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+
+	res, err := h.DriftInput(ctx, input, r)
+	if err != nil {
+		return false, err
+	}
+
+	// group by status
+	for _, x := range []struct {
+		status dirhash.DiffStatus
+		msg    string
+	}{
+		{dirhash.DiffAdded, "new"},
+		{dirhash.DiffRemoved, "missing"},
+		{dirhash.DiffModified, "changed"},
+		{dirhash.DiffTypeChanged, "type changed"},
+	} {
+		n := res.NumStatus(x.status)
+		if n == 0 {
+			continue
+		}
+		fmt.Printf("%d %s file(s)\n", n, x.msg)
+		for _, e := range res.Entries {
+			if e.Status != x.status {
+				continue
+			}
+			if e.Status == dirhash.DiffTypeChanged {
+				fmt.Printf("%s (%s)\n", dirhash.EscapePath(e.Path), e.New.GetTypeString())
+			} else {
+				fmt.Println(dirhash.EscapePath(e.Path))
+			}
+		}
+	}
+
+	return len(res.Entries) == 0, nil
+}
@@ -0,0 +1,127 @@
+package dirhash
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+type DiffStatus int
+
+const (
+	DiffAdded DiffStatus = iota
+	DiffRemoved
+	DiffModified
+	DiffTypeChanged
+)
+
+func (s DiffStatus) String() string {
+	switch s {
+	case DiffAdded:
+		return "ADDED"
+	case DiffRemoved:
+		return "REMOVED"
+	case DiffModified:
+		return "MODIFIED"
+	case DiffTypeChanged:
+		return "TYPE CHANGED"
+	default:
+		panic(fmt.Sprintf("unknown diff status %d", s))
+	}
+}
+
+type DiffEntry struct {
+	Path   string // path relative to input, "." if input is not a directory
+	Status DiffStatus
+	Old    *Entry // nil if added
+	New    *Entry // nil if removed
+}
+
+type DiffResult struct {
+	Entries []*DiffEntry // sorted by path
+}
+
+func (r *DiffResult) NumStatus(s DiffStatus) uint {
+	n := uint(0)
+	for _, e := range r.Entries {
+		if e.Status == s {
+			n++
+		}
+	}
+	return n
+}
+
+// DiffInput walks f1 and f2 with the same options, and returns entries
+// which differ between the two.
+func (h *Hasher) DiffInput(ctx context.Context, f1 string, f2 string) (*DiffResult, error) {
+	m1, err := h.collectEntry(ctx, f1)
+	if err != nil {
+		return nil, err
+	}
+	m2, err := h.collectEntry(ctx, f2)
+	if err != nil {
+		return nil, err
+	}
+
+	var l []string
+	for k := range m1 {
+		l = append(l, k)
+	}
+	for k := range m2 {
+		if _, ok := m1[k]; !ok {
+			l = append(l, k)
+		}
+	}
+	sort.Strings(l)
+
+	res := &DiffResult{}
+	for _, k := range l {
+		e1, ok1 := m1[k]
+		e2, ok2 := m2[k]
+		d := &DiffEntry{Path: k, Old: e1, New: e2}
+		if !ok1 {
+			d.Status = DiffAdded
+		} else if !ok2 {
+			d.Status = DiffRemoved
+		} else if e1.Type != e2.Type || (len(e1.Symlink) > 0) != (len(e2.Symlink) > 0) {
+			d.Status = DiffTypeChanged
+		} else if getHexSum(e1.Digest) != getHexSum(e2.Digest) || e1.Path != e2.Path {
+			d.Status = DiffModified
+		} else {
+			continue
+		}
+		res.Entries = append(res.Entries, d)
+	}
+
+	return res, nil
+}
+
+// collectEntry returns entries of input f keyed by path relative to f.
+func (h *Hasher) collectEntry(ctx context.Context, f string) (map[string]*Entry, error) {
+	t, err := getRawFileType(h.fs, f)
+	if err != nil {
+		return nil, err
+	}
+
+	// squash to include directories
+	x := h.newSubHasher()
+	x.opt.Squash = true
+	x.listDir = true
+	x.opt.Abs = false
+	m := make(map[string]*Entry)
+	if _, err := x.WalkInput(ctx, f, func(e *Entry) error {
+		k := e.Path
+		if len(e.Symlink) > 0 {
+			k = e.Symlink
+		}
+		if t != TypeDir {
+			k = "."
+		}
+		m[k] = e
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
@@ -0,0 +1,109 @@
+package dirhash
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_DiffInput(t *testing.T) {
+	d1 := newTestTree(t)
+	d2 := newTestTree(t)
+	h, err := NewHasher(Options{HashAlgo: SHA256, FollowSymlink: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := h.DiffInput(context.Background(), d1, d2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Entries) != 0 {
+		t.Error(res.Entries)
+	}
+
+	if err := os.WriteFile(filepath.Join(d2, "a/x"), []byte("xxx"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(d2, "z")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(d2, "z"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(d2, "a/b/y")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(d2, "w"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = h.DiffInput(context.Background(), d1, d2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffList := []struct {
+		path   string
+		status DiffStatus
+	}{
+		{"a/b/y", DiffRemoved},
+		{"a/x", DiffModified},
+		{"l", DiffModified},
+		{"w", DiffAdded},
+		{"z", DiffTypeChanged},
+	}
+	if len(res.Entries) != len(diffList) {
+		t.Fatal(res.Entries)
+	}
+	for i, x := range diffList {
+		if e := res.Entries[i]; e.Path != x.path || e.Status != x.status {
+			t.Error(i, e)
+		}
+	}
+
+	res, err = h.DiffInput(context.Background(), filepath.Join(d1, "a/b/y"),
+		filepath.Join(d2, "a/x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Entries) != 1 || res.Entries[0].Path != "." ||
+		res.Entries[0].Status != DiffModified {
+		t.Error(res.Entries)
+	}
+}
+
+func Test_DiffInputIgnoreDot(t *testing.T) {
+	d1 := newTestTree(t)
+	d2 := newTestTree(t)
+	for _, f := range []string{".git/objects/ab/x", ".git/.keep", "a/.y"} {
+		x := filepath.Join(d1, f)
+		if err := os.MkdirAll(filepath.Dir(x), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(x, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// dot directories are ignored with their entries
+	for _, opt := range []Options{{IgnoreDotDir: true}, {IgnoreDot: true}} {
+		opt.HashAlgo = SHA256
+		h, err := NewHasher(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := h.DiffInput(context.Background(), d1, d2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if opt.IgnoreDotDir {
+			if len(res.Entries) != 1 || res.Entries[0].Path != "a/.y" ||
+				res.Entries[0].Status != DiffRemoved {
+				t.Error(opt, res.Entries)
+			}
+		} else if len(res.Entries) != 0 {
+			t.Error(opt, res.Entries)
+		}
+	}
+}
@@ -1,176 +1,308 @@
-package main
+package dirhash
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
 	"path"
 	"path/filepath"
-	"sort"
 	"strings"
 )
 
-var (
-	inputPrefix string
-)
+func (h *Hasher) hashInput(ctx context.Context, f string) (*Result, error) {
+	res := &Result{}
+	if err := h.printInput(ctx, f, res); err != nil {
+		if ctx.Err() != nil {
+			res.Lines = h.lines
+			res.Stat = h.getStat()
+			return res, err
+		}
+		return nil, err
+	}
+	res.Lines = h.lines
+	res.Stat = h.getStat()
+	return res, nil
+}
 
-func printInput(f string) error {
-	// keep symlink input as is
-	if t, err := getRawFileType(f); err != nil {
+func (h *Hasher) printInput(ctx context.Context, f string, res *Result) error {
+	f, err := h.initInput(f)
+	if err != nil {
+		return err
+	} else if len(f) == 0 {
+		return nil
+	}
+
+	// initialize per walk resource
+	h.stat.initStat()
+	h.squash.init()
+	h.links = newLinkTable()
+	h.ignores = newIgnoreTable(h.opt.GitIgnore)
+
+	// write progress if specified
+	p, err := h.startProgress(ctx, f)
+	if err != nil {
 		return err
-	} else if t != typeSymlink {
-		if x, err := canonicalizePath(f); err != nil {
+	}
+	h.progress = p
+
+	// print entries in json unless caller takes them
+	h.jsonFormat = h.isJSONFormat() && h.fn == nil
+	if h.jsonFormat {
+		h.fn = h.printJSONEntry
+		h.printJSONHeader()
+	}
+
+	// keep paths of stats only if printed
+	h.stat.keepIgnored = h.opt.Verbose || h.jsonFormat
+	h.stat.keepHardlink = h.opt.ReportHardlink || h.jsonFormat
+
+	// start directory walk
+	err = h.walkDirectory(ctx, f)
+	h.progress.stop()
+	h.progress = nil
+	if err != nil {
+		// print partial stats if interrupted
+		if ctx.Err() != nil {
+			if h.jsonFormat {
+				_ = h.printJSONSummary(nil)
+			} else {
+				h.printVerboseStat()
+				h.printStatUnsupported()
+				h.printStatInvalid()
+				h.printStatCacheMismatch()
+				h.printStatSymlinkLoop()
+				if h.opt.ReportHardlink {
+					h.printStatHardlink()
+				}
+			}
+		}
+		return err
+	}
+
+	// print various stats
+	if !h.jsonFormat {
+		if h.opt.Verbose {
+			h.printVerboseStat()
+		}
+		h.printStatUnsupported()
+		h.printStatInvalid()
+		h.printStatCacheMismatch()
+		h.printStatSymlinkLoop()
+		if h.opt.ReportHardlink {
+			h.printStatHardlink()
+		}
+	}
+
+	// print squash hash if specified
+	var sum []byte
+	if h.opt.Squash {
+		b := h.squash.get()
+		if h.opt.Verbose && !h.jsonFormat {
+			h.printNumFormatString(uint(len(b)), "squashed byte")
+		}
+		sum, err = h.printByte(f, b)
+		if err != nil {
 			return err
+		}
+		if h.testHashVerify(sum) {
+			res.Squash = sum
+		}
+	}
+
+	if h.jsonFormat {
+		return h.printJSONSummary(sum)
+	}
+
+	return nil
+}
+
+// initInput returns abs path of input f and sets input prefix.
+func (h *Hasher) initInput(f string) (string, error) {
+	// keep symlink input as is
+	if t, err := getRawFileType(h.fs, f); err != nil {
+		return "", err
+	} else if t != TypeSymlink {
+		if x, err := canonicalizePath(h.fs, f); err != nil {
+			return "", err
 		} else if len(x) == 0 {
-			return nil
+			return "", nil
 		} else {
 			f = x
 		}
 		// assert exists
-		if _, err := pathExists(f); err != nil {
-			return err
+		if _, err := pathExists(h.fs, f); err != nil {
+			return "", err
 		}
 	}
 
 	// convert input to abs first
-	f, err := filepath.Abs(f)
+	f, err := h.fs.abs(f)
 	if err != nil {
-		return err
+		return "", err
 	}
-	assertFilePath(f)
+	h.assertFilePath(f)
 
 	// keep input prefix based on raw type
-	t, err := getRawFileType(f)
+	t, err := getRawFileType(h.fs, f)
 	if err != nil {
-		return err
+		return "", err
 	}
 	switch t {
-	case typeDir:
-		inputPrefix = f
-	case typeReg:
+	case TypeDir:
+		h.inputPrefix = f
+	case TypeReg:
 		fallthrough
-	case typeDevice:
+	case TypeDevice:
 		fallthrough
-	case typeSymlink:
-		inputPrefix = filepath.Dir(f)
+	case TypeSymlink:
+		h.inputPrefix = filepath.Dir(f)
 	default:
-		return fmt.Errorf("%s has unsupported type %d", f, t)
+		return "", fmt.Errorf("%s has unsupported type %d", f, t)
 	}
 
 	// prefix is a directory
-	t, _ = getFileType(inputPrefix)
-	assert(t == typeDir)
+	t, _ = getFileType(h.fs, h.inputPrefix)
+	assert(t == TypeDir)
 
-	// initialize global resource
-	initStat()
-	initSquashBuffer()
+	return f, nil
+}
 
-	// start directory walk
-	if err := walkDirectory(f); err != nil {
-		return err
+func (h *Hasher) walkDirectory(ctx context.Context, f string) error {
+	// hash files concurrently if specified
+	var q *fileQueue
+	visit := func(f string) error {
+		return h.walkDirectoryImpl(ctx, f)
 	}
-
-	// print various stats
-	if optVerbose {
-		printVerboseStat()
+	if h.opt.Jobs > 1 {
+		q = h.newFileQueue(ctx)
+		defer q.close()
+		visit = q.push
 	}
-	printStatUnsupported()
-	printStatInvalid()
 
-	// print squash hash if specified
-	if optSquash {
-		b := getSquashBuffer()
-		if optVerbose {
-			printNumFormatString(uint(len(b)), "squashed byte")
-		}
-		if err := printByte(f, b); err != nil {
-			return err
-		}
+	w, err := h.newWalkLimit(f)
+	if err != nil {
+		return err
 	}
 
-	return nil
-}
-
-func walkDirectory(f string) error {
-	var l []string
-	if err := filepath.WalkDir(f,
+	if err := h.walkDir(f,
 		func(f string, d fs.DirEntry, err error) error {
-			assertFilePath(f)
+			h.assertFilePath(f)
 			if err != nil {
 				return err
 			}
-			if optSort {
-				l = append(l, f)
-				return nil
-			} else {
-				return walkDirectoryImpl(f)
+			if err := ctx.Err(); err != nil {
+				return err
 			}
-		}); err != nil {
-		return err
-	}
-
-	if optSort {
-		sort.Strings(l)
-		for _, f := range l {
-			if err := walkDirectoryImpl(f); err != nil {
+			// skip mount point if specified
+			if mount, err := w.isMountPoint(f, d); err != nil {
 				return err
+			} else if mount {
+				h.stat.appendStatMountPoint(f)
+				return filepath.SkipDir
 			}
-		}
+			// visit entries within depth if specified
+			ok, skip := w.testDepth(f, d)
+			if ok {
+				if err := visit(f); err != nil {
+					return err
+				}
+			}
+			if skip != nil {
+				return skip
+			}
+			// prune ignored directory
+			if d.IsDir() {
+				if ignored, err := h.testIgnoreEntry(f, TypeDir); err != nil {
+					return err
+				} else if ignored {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}, h.stat.appendStatSymlinkLoop); err != nil {
+		return err
 	}
 
+	if q != nil {
+		return q.flush()
+	}
 	return nil
 }
 
-func walkDirectoryImpl(f string) error {
-	t, err := getRawFileType(f)
+// walkDir walks f with entries sorted per directory if Options.Sort instead
+// of collecting all paths, and walks into symlinks to directories if
+// Options.FollowDirSymlink, where loop is called on symlink loops.
+func (h *Hasher) walkDir(f string, fn fs.WalkDirFunc, loop func(string)) error {
+	follow := h.opt.FollowDirSymlink && !h.opt.IgnoreSymlink
+	if !h.opt.Sort && !follow {
+		return h.fs.walkDir(f, fn)
+	}
+	return newDirWalker(h.fs, fn, h.opt.Sort, follow, loop).walk(f)
+}
+
+func (h *Hasher) walkDirectoryImpl(ctx context.Context, f string) error {
+	t, err := getRawFileType(h.fs, f)
 	if err != nil {
 		return err
 	}
 
-	if testIgnoreEntry(f, t) {
-		appendStatIgnored(f)
+	if ignored, err := h.testIgnoreEntry(f, t); err != nil {
+		return err
+	} else if ignored {
+		h.stat.appendStatIgnored(f)
 		return nil
 	}
 
 	// find target if symlink
 	var x, l string // l is symlink itself, not its target
 	switch t {
-	case typeSymlink:
-		if optIgnoreSymlink {
-			appendStatIgnored(f)
+	case TypeSymlink:
+		if h.opt.IgnoreSymlink {
+			h.stat.appendStatIgnored(f)
 			return nil
 		}
-		if !optFollowSymlink {
-			return printSymlink(f)
+		if !h.opt.FollowSymlink {
+			x = f // symlink itself
+			break
 		}
-		x, err = canonicalizePath(f)
+		x, err = canonicalizePath(h.fs, f)
 		if err != nil {
 			return err
 		} else if len(x) == 0 {
-			return printInvalid(f)
+			return h.printInvalid(f)
 		}
 		assert(filepath.IsAbs(x))
-		t, err = getFileType(x) // update type
+		t, err = getFileType(h.fs, x) // update type
 		if err != nil {
 			return err
 		}
-		assert(t != typeSymlink) // symlink chains resolved
+		assert(t != TypeSymlink) // symlink chains resolved
 		l = f
 	default:
 		x = f
 		l = ""
 	}
 
+	if ignored, err := h.testFilterEntry(x, t); err != nil {
+		return err
+	} else if ignored {
+		h.stat.appendStatIgnored(f)
+		return nil
+	}
+
 	switch t {
-	case typeDir:
-		return handleDirectory(x, l)
-	case typeReg:
+	case TypeSymlink:
+		return h.printSymlink(x)
+	case TypeDir:
+		return h.handleDirectory(x, l)
+	case TypeReg:
 		fallthrough
-	case typeDevice:
-		return printFile(x, l, t)
-	case typeUnsupported:
-		return printUnsupported(x)
-	case typeInvalid:
-		return printInvalid(x)
+	case TypeDevice:
+		return h.printFile(ctx, x, l, t)
+	case TypeUnsupported:
+		return h.printUnsupported(x)
+	case TypeInvalid:
+		return h.printInvalid(x)
 	default:
 		panicFileType(x, "unknown", t)
 	}
@@ -179,236 +311,347 @@ func walkDirectoryImpl(f string) error {
 	return nil
 }
 
-func testIgnoreEntry(f string, t fileType) bool {
+func (h *Hasher) testIgnoreEntry(f string, t FileType) (bool, error) {
 	assert(filepath.IsAbs(f))
 
-	// only non directory types count
-	if t == typeDir {
-		return false
-	}
-
 	baseStartsWithDot := strings.HasPrefix(path.Base(f), ".")
 	pathContainsSlashDot := strings.Contains(f, "/.")
 
+	// directories are ignored with their entries, except for input itself,
+	// and dot directories keep squash of older versions unchanged
+	if t == TypeDir {
+		if f == h.inputPrefix || !strings.HasPrefix(f, h.inputPrefix) {
+			return false, nil
+		}
+		if (h.opt.IgnoreDotDir || h.opt.IgnoreDot) && baseStartsWithDot &&
+			(!h.opt.Squash || squashPruneDotDir || h.listDir) {
+			return true, nil
+		}
+		if matchGlobPatternList(h.exclude, h.trimInputPrefix(f)) {
+			return true, nil
+		}
+		return h.testIgnoreFile(f, true)
+	}
+
 	// ignore . directories if specified
-	if optIgnoreDotDir {
+	if h.opt.IgnoreDotDir {
 		if !baseStartsWithDot && pathContainsSlashDot {
-			return true
+			return true, nil
 		}
 	}
 
 	// ignore . regular files if specified
-	if optIgnoreDotFile {
-		// XXX limit to typeReg ?
+	if h.opt.IgnoreDotFile {
+		// XXX limit to TypeReg ?
 		if baseStartsWithDot {
-			return true
+			return true, nil
 		}
 	}
 
 	// ignore . entries if specified
-	if optIgnoreDot {
+	if h.opt.IgnoreDot {
 		if baseStartsWithDot || pathContainsSlashDot {
-			return true
+			return true, nil
 		}
 	}
 
-	return false
+	// ignore entries by relative path patterns if specified
+	if len(h.include) > 0 || len(h.exclude) > 0 {
+		x := h.trimInputPrefix(f)
+		if len(h.include) > 0 && !matchGlobPatternList(h.include, x) {
+			return true, nil
+		}
+		if matchGlobPatternList(h.exclude, x) {
+			return true, nil
+		}
+	}
+
+	// ignore entries by ignore files
+	return h.testIgnoreFile(f, false)
 }
 
-func trimInputPrefix(f string) string {
-	if strings.HasPrefix(f, inputPrefix) {
-		f = f[len(inputPrefix)+1:]
+// testFilterEntry returns true if entry f of type t, which is symlink
+// target if followed, is ignored by type, size or time predicates.
+func (h *Hasher) testFilterEntry(f string, t FileType) (bool, error) {
+	// directories are only hashed if squash, and are walked regardless
+	if t == TypeDir && (!h.opt.Squash || f == h.inputPrefix) {
+		return false, nil
+	}
+
+	if len(h.opt.Type) > 0 {
+		found := false
+		for _, x := range h.opt.Type {
+			if t == x {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return true, nil
+		}
+	}
+
+	// size only applies to regular files
+	testSize := t == TypeReg && (h.opt.MinSize > 0 || h.opt.MaxSize > 0)
+	testTime := !h.opt.Newer.IsZero() || !h.opt.Older.IsZero()
+	if !testSize && !testTime {
+		return false, nil
+	}
+	info, err := h.fs.lstat(f)
+	if err != nil {
+		return false, err
+	}
+
+	if testSize {
+		size := info.Size()
+		if size < h.opt.MinSize || (h.opt.MaxSize > 0 && size > h.opt.MaxSize) {
+			return true, nil
+		}
+	}
+
+	if testTime {
+		mtime := info.ModTime()
+		if !h.opt.Newer.IsZero() && !mtime.After(h.opt.Newer) {
+			return true, nil
+		}
+		if !h.opt.Older.IsZero() && !mtime.Before(h.opt.Older) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (h *Hasher) trimInputPrefix(f string) string {
+	if h.inputPrefix == "/" {
+		assert(strings.HasPrefix(f, "/"))
+		return f[1:]
+	} else if strings.HasPrefix(f, h.inputPrefix) {
+		f = f[len(h.inputPrefix)+1:]
 		assert(!strings.HasPrefix(f, "/"))
 	}
 	return f
 }
 
-func getRealPath(f string) string {
-	if optAbs {
+func (h *Hasher) getRealPath(f string) string {
+	if h.opt.Abs {
 		assert(filepath.IsAbs(f))
 		return f
-	} else if f == inputPrefix {
+	} else if f == h.inputPrefix {
 		return "."
-	} else if inputPrefix == "/" {
+	} else if h.inputPrefix == "/" {
 		return f[1:]
 	} else {
 		// f is probably symlink target if f unchanged
-		return trimInputPrefix(f)
+		return h.trimInputPrefix(f)
 	}
 }
 
-func printByte(f string, inb []byte) error {
-	assertFilePath(f)
+func (h *Hasher) printByte(f string, inb []byte) ([]byte, error) {
+	h.assertFilePath(f)
 
 	// get hash value
-	_, b, err := getByteHash(inb, optHashAlgo)
+	_, b, err := getByteHash(inb, h.opt.HashAlgo)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	assert(len(b) > 0)
 	hexSum := getHexSum(b)
 
 	// verify hash value if specified
-	if len(optHashVerify) != 0 {
-		if optHashVerify != hexSum {
-			return nil
-		}
+	if !h.testHashVerify(b) {
+		return b, nil
+	}
+
+	// caller takes squash hash from result
+	if h.fn != nil {
+		return b, nil
 	}
 
-	if optHashOnly {
-		fmt.Println(hexSum)
+	if h.opt.HashOnly {
+		h.println(hexSum)
 	} else {
 		// no space between two
 		s := fmt.Sprintf("[%s][v%d]", squashLabel, squashVersion)
-		if realf := getRealPath(f); realf == "." {
-			fmt.Println(hexSum + s)
+		if realf := h.getRealPath(f); realf == "." {
+			h.println(hexSum + s)
 		} else {
-			fmt.Println(getXsumFormatString(realf, hexSum) + s)
+			h.println(h.getXsumFormatString(realf, hexSum) + s)
 		}
 	}
 
-	return nil
+	return b, nil
 }
 
-func handleDirectory(f string, l string) error {
-	assertFilePath(f)
+func (h *Hasher) handleDirectory(f string, l string) error {
+	h.assertFilePath(f)
 	if len(l) > 0 {
-		assertFilePath(l)
+		h.assertFilePath(l)
 	}
 
 	// nothing to do if input is input prefix
-	if f == inputPrefix {
+	if f == h.inputPrefix {
 		return nil
 	}
 
 	// nothing to do unless squash
-	if !optSquash {
+	if !h.opt.Squash {
 		return nil
 	}
 
 	// debug print first
-	if optDebug {
-		if err := printDebug(f, typeDir); err != nil {
+	if h.opt.Debug {
+		if err := h.printDebug(f, TypeDir); err != nil {
 			return err
 		}
 	}
 
 	// get hash value
 	// path must be relative to input prefix
-	s := trimInputPrefix(f)
-	written, b, err := getStringHash(s, optHashAlgo)
+	s := h.trimInputPrefix(f)
+	written, b, err := getStringHash(s, h.opt.HashAlgo)
 	if err != nil {
 		return err
 	}
 	assert(len(b) > 0)
 
 	// count this file
-	appendStatTotal()
-	appendWrittenTotal(written)
-	appendStatDirectory(f)
-	appendWrittenDirectory(written)
+	h.stat.appendStatTotal()
+	h.stat.appendWrittenTotal(written)
+	h.stat.appendStatDirectory()
+	h.stat.appendWrittenDirectory(written)
+
+	// pass this directory to caller if specified
+	if err := h.visitEntry(f, l, TypeDir, b, written); err != nil {
+		return err
+	}
 
 	// squash
-	assert(optSquash)
-	if optHashOnly {
-		updateSquashBuffer(b)
+	assert(h.opt.Squash)
+	if h.opt.HashOnly {
+		h.squash.update(b)
 	} else {
 		// make link -> target format if symlink
-		realf := getRealPath(f)
+		realf := h.getRealPath(f)
 		if len(l) > 0 {
-			assertFilePath(l)
-			if !optAbs {
-				l = trimInputPrefix(l)
+			h.assertFilePath(l)
+			if !h.opt.Abs {
+				l = h.trimInputPrefix(l)
 			}
 			realf = fmt.Sprintf("%s -> %s", l, realf)
 		}
-		updateSquashBuffer(append([]byte(realf), b...))
+		h.squash.update(append([]byte(realf), b...))
 	}
 
 	return nil
 }
 
-func printFile(f string, l string, t fileType) error {
-	assertFilePath(f)
+func (h *Hasher) printFile(ctx context.Context, f string, l string, t FileType) error {
+	h.assertFilePath(f)
 	if len(l) > 0 {
-		assertFilePath(l)
+		h.assertFilePath(l)
 	}
 
 	// debug print first
-	if optDebug {
-		if err := printDebug(f, t); err != nil {
+	if h.opt.Debug {
+		if err := h.printDebug(f, t); err != nil {
 			return err
 		}
 	}
 
 	// get hash value
-	written, b, err := getFileHash(f, optHashAlgo)
+	x, err := h.getFileHash(ctx, f)
 	if err != nil {
 		return err
 	}
+	if x.mismatch {
+		h.stat.appendStatCacheMismatch(f)
+	}
+	written, b := x.written, x.b
 	assert(len(b) > 0)
+	h.stat.appendHoleSkipped(x.skipped)
+
+	// find the first link in walk order if hardlinked
+	var link string
+	if x.st != nil && x.st.nlink > 1 {
+		link = h.stat.appendStatHardlink(f, x.st.getKey())
+	}
 	hexSum := getHexSum(b)
 
 	// count this file
-	appendStatTotal()
-	appendWrittenTotal(written)
+	h.stat.appendStatTotal()
+	h.stat.appendWrittenTotal(written)
 	switch t {
-	case typeReg:
-		appendStatRegular(f)
-		appendWrittenRegular(written)
-	case typeDevice:
-		appendStatDevice(f)
-		appendWrittenDevice(written)
+	case TypeReg:
+		h.stat.appendStatRegular()
+		h.stat.appendWrittenRegular(written)
+	case TypeDevice:
+		h.stat.appendStatDevice()
+		h.stat.appendWrittenDevice(written)
 	default:
 		panicFileType(f, "invalid", t)
 	}
 
+	// pass this file to caller if specified
+	if err := h.visitEntry(f, l, t, b, written); err != nil {
+		return err
+	}
+
 	// verify hash value if specified
-	if len(optHashVerify) != 0 {
-		if optHashVerify != hexSum {
+	if len(h.opt.HashVerify) != 0 {
+		if h.opt.HashVerify != hexSum {
 			return nil
 		}
 	}
 
+	// squash hardlinked inode once if specified
+	if h.opt.Squash && h.opt.SquashHardlinkOnce && len(link) > 0 {
+		return nil
+	}
+
 	// squash or print this file
-	if optHashOnly {
-		if optSquash {
-			updateSquashBuffer(b)
-		} else {
-			fmt.Println(hexSum)
+	if h.opt.HashOnly {
+		if h.opt.Squash {
+			h.squash.update(b)
+		} else if h.fn == nil {
+			h.println(hexSum)
 		}
 	} else {
 		// make link -> target format if symlink
-		realf := getRealPath(f)
+		realf := h.getRealPath(f)
 		if len(l) > 0 {
-			assertFilePath(l)
-			if !optAbs {
-				l = trimInputPrefix(l)
+			h.assertFilePath(l)
+			if !h.opt.Abs {
+				l = h.trimInputPrefix(l)
 			}
 			realf = fmt.Sprintf("%s -> %s", l, realf)
 		}
-		if optSquash {
-			updateSquashBuffer(append([]byte(realf), b...))
-		} else {
-			fmt.Println(getXsumFormatString(realf, hexSum))
+		if h.opt.Squash {
+			h.squash.update(append([]byte(realf), b...))
+		} else if h.fn == nil {
+			h.println(h.getXsumFormatString(realf, hexSum))
 		}
 	}
 
 	return nil
 }
 
-func printSymlink(f string) error {
-	assertFilePath(f)
+func (h *Hasher) printSymlink(f string) error {
+	h.assertFilePath(f)
 
 	// debug print first
-	if optDebug {
-		if err := printDebug(f, typeSymlink); err != nil {
+	if h.opt.Debug {
+		if err := h.printDebug(f, TypeSymlink); err != nil {
 			return err
 		}
 	}
 
-	// get hash value of symlink base name
-	written, b, err := getStringHash(path.Base(f), optHashAlgo)
+	// get hash value of symlink base name or target
+	s, err := h.getSymlinkHashString(f)
+	if err != nil {
+		return err
+	}
+	written, b, err := getStringHash(s, h.opt.HashAlgo)
 	if err != nil {
 		return err
 	}
@@ -416,131 +659,163 @@ func printSymlink(f string) error {
 	hexSum := getHexSum(b)
 
 	// count this file
-	appendStatTotal()
-	appendWrittenTotal(written)
-	appendStatSymlink(f)
-	appendWrittenSymlink(written)
+	h.stat.appendStatTotal()
+	h.stat.appendWrittenTotal(written)
+	h.stat.appendStatSymlink()
+	h.stat.appendWrittenSymlink(written)
+
+	// pass this file to caller if specified
+	if err := h.visitEntry(f, "", TypeSymlink, b, written); err != nil {
+		return err
+	}
 
 	// verify hash value if specified
-	if len(optHashVerify) != 0 {
-		if optHashVerify != hexSum {
+	if len(h.opt.HashVerify) != 0 {
+		if h.opt.HashVerify != hexSum {
 			return nil
 		}
 	}
 
 	// squash or print this file
-	if optHashOnly {
-		if optSquash {
-			updateSquashBuffer(b)
-		} else {
-			fmt.Println(hexSum)
+	if h.opt.HashOnly {
+		if h.opt.Squash {
+			h.squash.update(b)
+		} else if h.fn == nil {
+			h.println(hexSum)
 		}
 	} else {
-		if realf := getRealPath(f); optSquash {
-			updateSquashBuffer(append([]byte(realf), b...))
-		} else {
-			fmt.Println(getXsumFormatString(realf, hexSum))
+		if realf := h.getRealPath(f); h.opt.Squash {
+			h.squash.update(append([]byte(realf), b...))
+		} else if h.fn == nil {
+			h.println(h.getXsumFormatString(realf, hexSum))
 		}
 	}
 
 	return nil
 }
 
-func printUnsupported(f string) error {
-	if optDebug {
-		if err := printDebug(f, typeUnsupported); err != nil {
+func (h *Hasher) testHashVerify(b []byte) bool {
+	return len(h.opt.HashVerify) == 0 || h.opt.HashVerify == getHexSum(b)
+}
+
+func (h *Hasher) visitEntry(f string, l string, t FileType, b []byte,
+	written uint64) error {
+	if h.fn == nil {
+		return nil
+	}
+
+	e := &Entry{
+		Path:     h.getRealPath(f),
+		Type:     t,
+		Digest:   b,
+		Written:  written,
+		Verified: len(h.opt.HashVerify) != 0 && h.opt.HashVerify == getHexSum(b),
+	}
+	if len(l) > 0 {
+		h.assertFilePath(l)
+		if h.opt.Abs {
+			e.Symlink = l
+		} else {
+			e.Symlink = h.trimInputPrefix(l)
+		}
+	}
+	return h.fn(e)
+}
+
+func (h *Hasher) printUnsupported(f string) error {
+	if h.opt.Debug {
+		if err := h.printDebug(f, TypeUnsupported); err != nil {
 			return err
 		}
 	}
 
-	appendStatUnsupported(f)
+	h.stat.appendStatUnsupported(f)
 	return nil
 }
 
-func printInvalid(f string) error {
-	if optDebug {
-		if err := printDebug(f, typeInvalid); err != nil {
+func (h *Hasher) printInvalid(f string) error {
+	if h.opt.Debug {
+		if err := h.printDebug(f, TypeInvalid); err != nil {
 			return err
 		}
 	}
 
-	appendStatInvalid(f)
+	h.stat.appendStatInvalid(f)
 	return nil
 }
 
-func printDebug(f string, t fileType) error {
-	assert(optDebug)
-	if optAbs {
+func (h *Hasher) printDebug(f string, t FileType) error {
+	assert(h.opt.Debug)
+	if h.jsonFormat {
+		return nil
+	}
+	if h.opt.Abs {
 		var err error
-		f, err = filepath.Abs(f)
+		f, err = h.fs.abs(f)
 		if err != nil {
 			return err
 		}
 	}
 
-	fmt.Println("###", f, getFileTypeString(t))
+	h.println("###", f, getFileTypeString(t))
 	return nil
 }
 
-func printVerboseStat() {
+func (h *Hasher) printVerboseStat() {
 	indent := " "
 
-	printNumFormatString(numStatTotal(), "file")
-	a0 := numStatDirectory()
-	a1 := numStatRegular()
-	a2 := numStatDevice()
-	a3 := numStatSymlink()
-	assert(a0+a1+a2+a3 == numStatTotal())
+	h.printNumFormatString(h.stat.numStatTotal(), "file")
+	a0 := h.stat.numStatDirectory()
+	a1 := h.stat.numStatRegular()
+	a2 := h.stat.numStatDevice()
+	a3 := h.stat.numStatSymlink()
+	assert(a0+a1+a2+a3 == h.stat.numStatTotal())
 	if a0 > 0 {
-		fmt.Print(indent)
-		printNumFormatString(a0, strDir)
+		h.println(indent + getNumFormatString(a0, strDir))
 	}
 	if a1 > 0 {
-		fmt.Print(indent)
-		printNumFormatString(a1, strReg)
+		h.println(indent + getNumFormatString(a1, strReg))
 	}
 	if a2 > 0 {
-		fmt.Print(indent)
-		printNumFormatString(a2, strDevice)
+		h.println(indent + getNumFormatString(a2, strDevice))
 	}
 	if a3 > 0 {
-		fmt.Print(indent)
-		printNumFormatString(a3, strSymlink)
+		h.println(indent + getNumFormatString(a3, strSymlink))
 	}
 
-	printNumFormatString(numWrittenTotal(), "byte")
-	b0 := numWrittenDirectory()
-	b1 := numWrittenRegular()
-	b2 := numWrittenDevice()
-	b3 := numWrittenSymlink()
-	assert(b0+b1+b2+b3 == numWrittenTotal())
+	h.printNumFormatString(h.stat.numWrittenTotal(), "byte")
+	b0 := h.stat.numWrittenDirectory()
+	b1 := h.stat.numWrittenRegular()
+	b2 := h.stat.numWrittenDevice()
+	b3 := h.stat.numWrittenSymlink()
+	assert(b0+b1+b2+b3 == h.stat.numWrittenTotal())
 	if b0 > 0 {
-		fmt.Print(indent)
-		printNumFormatString(b0, strDir+" byte")
+		h.println(indent + getNumFormatString(b0, strDir+" byte"))
 	}
 	if b1 > 0 {
-		fmt.Print(indent)
-		printNumFormatString(b1, strReg+" byte")
+		h.println(indent + getNumFormatString(b1, strReg+" byte"))
 	}
 	if b2 > 0 {
-		fmt.Print(indent)
-		printNumFormatString(b2, strDevice+" byte")
+		h.println(indent + getNumFormatString(b2, strDevice+" byte"))
 	}
 	if b3 > 0 {
-		fmt.Print(indent)
-		printNumFormatString(b3, strSymlink+" byte")
+		h.println(indent + getNumFormatString(b3, strSymlink+" byte"))
+	}
+	if n := h.stat.numHoleSkipped(); n > 0 {
+		h.printNumFormatString(n, "skipped hole byte")
 	}
 
-	printStatIgnored()
+	h.printStatIgnored()
+	h.printStatMountPoint()
 }
 
-func assertFilePath(f string) {
+func (h *Hasher) assertFilePath(f string) {
 	// must always handle file as abs
 	assert(filepath.IsAbs(f))
 
-	// file must not end with "/"
-	assert(!strings.HasSuffix(f, "/"))
+	// file must not end with "/" unless root
+	assert(f == "/" || !strings.HasSuffix(f, "/"))
 
-	// inputPrefix must not end with "/"
-	assert(!strings.HasSuffix(inputPrefix, "/"))
+	// input prefix must not end with "/" unless root
+	assert(h.inputPrefix == "/" || !strings.HasSuffix(h.inputPrefix, "/"))
 }
@@ -0,0 +1,303 @@
+// Package dirhash recursively walks directory trees and computes message
+// digest of regular files.
+//
+// The squash algorithm is selected by either squash1, squash2 or squash3
+// build tag, where squash2 is used without any of them.
+package dirhash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+type Options struct {
+	HashAlgo           string
+	HashVerify         string
+	HashOnly           bool
+	IgnoreDot          bool
+	IgnoreDotDir       bool
+	IgnoreDotFile      bool
+	IgnoreSymlink      bool
+	GitIgnore          bool       // ignore files by .gitignore and .dirhashignore
+	Include            []string   // ignore files unless matching any of Include
+	Exclude            []string   // ignore files matching any of Exclude
+	Type               []FileType // ignore entries unless any of Type if set
+	MinSize            int64      // ignore regular files smaller than MinSize
+	MaxSize            int64      // ignore regular files larger than MaxSize if > 0
+	Newer              time.Time  // ignore entries not modified after Newer if set
+	Older              time.Time  // ignore entries not modified before Older if set
+	MinDepth           int        // ignore entries less than MinDepth below input
+	MaxDepth           int        // walk entries up to MaxDepth below input if > 0
+	Xdev               bool       // do not walk directories on other devices
+	FollowSymlink      bool
+	FollowDirSymlink   bool   // walk into symlinks to directories
+	SymlinkHash        string // default of squash version if empty
+	Abs                bool
+	Swap               bool
+	Tag                bool
+	Zero               bool // terminate lines with NUL instead of newline
+	Sort               bool
+	Jobs               int       // hash files concurrently if > 1
+	Cache              *Cache    // reuse digest of unchanged regular files if set
+	CacheVerify        float64   // fraction of cache hits to re-hash
+	ChunkSize          int64     // tree hash files in chunks of ChunkSize if > 0
+	BwLimit            int64     // limit bytes read per second if > 0
+	ReportHardlink     bool      // print hardlinks of files hashed once
+	SquashHardlinkOnce bool      // squash hardlinked inode once by the first link
+	Progress           io.Writer // write progress periodically if set
+	ProgressScan       bool      // scan file sizes before walk for ETA
+	Squash             bool
+	Verbose            bool
+	Debug              bool
+	Format             string // FormatText if empty
+	FS                 fs.FS  // walk FS instead of host filesystem if set
+}
+
+// Hasher holds per walk state, so multiple Hashers can run concurrently.
+// A single Hasher must not be used concurrently, though it may hash files
+// concurrently within a walk if Options.Jobs is set.
+type Hasher struct {
+	opt         Options
+	fs          fileSystem
+	inputPrefix string
+	stat        *stat
+	squash      *squashBuffer
+	lines       []string
+	w           io.Writer // print lines to w if set
+	fn          EntryFunc // pass entries to fn instead of printing if set
+	job         *fileJob  // entry being consumed from fileQueue
+	links       *linkTable
+	progress    *progress
+	limiter     *rateLimiter // shared by sub Hashers
+	include     []*globPattern
+	exclude     []*globPattern
+	ignores     *ignoreTable
+	listDir     bool // Options.Squash only lists directories, not squashed
+
+	jsonFormat   bool // print in Options.Format of json
+	numJSONEntry uint
+}
+
+type Result struct {
+	Lines  []string // output lines in print order
+	Squash []byte   // squashed message digest if Options.Squash
+	Stat   *Stat
+}
+
+// Entry is a hashed entry of a walk.
+type Entry struct {
+	Path    string   // path relative to input prefix unless Options.Abs
+	Type    FileType // symlink target type if Symlink is set
+	Digest  []byte
+	Written uint64 // bytes written to hash
+	Symlink string // followed symlink path if any, same format as Path
+
+	// Verified is set if Digest matches Options.HashVerify.
+	// Entries are passed regardless of Options.HashVerify.
+	Verified bool
+}
+
+// getPath returns path in link -> target format if symlink.
+func (e *Entry) getPath() string {
+	if len(e.Symlink) > 0 {
+		return fmt.Sprintf("%s -> %s", e.Symlink, e.Path)
+	} else {
+		return e.Path
+	}
+}
+
+// GetTypeString returns type string of e, which includes symlink if
+// followed.
+func (e *Entry) GetTypeString() string {
+	if len(e.Symlink) > 0 {
+		return fmt.Sprintf("%s to %s", strSymlink, getFileTypeString(e.Type))
+	} else {
+		return getFileTypeString(e.Type)
+	}
+}
+
+// EntryFunc is called for each hashed entry in walk order.
+// Returning an error stops the walk.
+type EntryFunc func(e *Entry) error
+
+func NewHasher(opt Options) (*Hasher, error) {
+	opt.HashAlgo = strings.ToLower(opt.HashAlgo)
+	opt.HashVerify = strings.ToLower(opt.HashVerify)
+
+	if len(opt.HashAlgo) == 0 {
+		return nil, errors.New("no hash algorithm specified")
+	}
+	if NewHash(opt.HashAlgo) == nil {
+		return nil, fmt.Errorf("unsupported hash algorithm %s", opt.HashAlgo)
+	}
+
+	if len(opt.HashVerify) != 0 {
+		var valid bool
+		if opt.HashVerify, valid = IsValidHexSum(opt.HashVerify); !valid {
+			return nil, fmt.Errorf("invalid verify string %s", opt.HashVerify)
+		}
+	}
+	assert(opt.HashVerify == strings.ToLower(opt.HashVerify))
+
+	if len(opt.Format) == 0 {
+		opt.Format = FormatText
+	}
+	opt.Format = strings.ToLower(opt.Format)
+	if !isValidFormat(opt.Format) {
+		return nil, fmt.Errorf("unsupported format %s", opt.Format)
+	}
+
+	if len(opt.SymlinkHash) == 0 {
+		opt.SymlinkHash = squashSymlinkHash
+	}
+	opt.SymlinkHash = strings.ToLower(opt.SymlinkHash)
+	if !isValidSymlinkHash(opt.SymlinkHash) {
+		return nil, fmt.Errorf("unsupported symlink hash %s", opt.SymlinkHash)
+	}
+
+	if opt.Jobs < 0 {
+		return nil, fmt.Errorf("invalid jobs %d", opt.Jobs)
+	}
+
+	if opt.ChunkSize < 0 {
+		return nil, fmt.Errorf("invalid chunk size %d", opt.ChunkSize)
+	}
+
+	if opt.BwLimit < 0 {
+		return nil, fmt.Errorf("invalid bwlimit %d", opt.BwLimit)
+	}
+
+	for _, t := range opt.Type {
+		if t != TypeDir && t != TypeReg && t != TypeDevice && t != TypeSymlink {
+			return nil, fmt.Errorf("invalid type %d", t)
+		}
+	}
+
+	if opt.MinDepth < 0 {
+		return nil, fmt.Errorf("invalid min depth %d", opt.MinDepth)
+	}
+	if opt.MaxDepth < 0 {
+		return nil, fmt.Errorf("invalid max depth %d", opt.MaxDepth)
+	}
+
+	if opt.MinSize < 0 {
+		return nil, fmt.Errorf("invalid min size %d", opt.MinSize)
+	}
+	if opt.MaxSize < 0 {
+		return nil, fmt.Errorf("invalid max size %d", opt.MaxSize)
+	}
+
+	include, err := newGlobPatternList(opt.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := newGlobPatternList(opt.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	if opt.CacheVerify < 0 || opt.CacheVerify > 1 {
+		return nil, fmt.Errorf("invalid cache verify %v", opt.CacheVerify)
+	}
+
+	if isWindows() {
+		return nil, errors.New("windows unsupported")
+	}
+
+	if s := getPathSeparator(); s != '/' {
+		return nil, fmt.Errorf("invalid path separator %c", s)
+	}
+
+	var fsys fileSystem = hostFS{}
+	if opt.FS != nil {
+		fsys = newIOFS(opt.FS)
+	}
+
+	return &Hasher{
+		opt:     opt,
+		fs:      fsys,
+		stat:    newStat(),
+		squash:  newSquashBuffer(),
+		links:   newLinkTable(),
+		ignores: newIgnoreTable(opt.GitIgnore),
+		limiter: newRateLimiter(opt.BwLimit),
+		include: include,
+		exclude: exclude,
+	}, nil
+}
+
+// newSubHasher returns a copy of h with its own per walk resource, which
+// is used to hash entries out of the main walk.
+func (h *Hasher) newSubHasher() *Hasher {
+	x := *h
+	x.opt.HashVerify = ""
+	x.opt.Squash = false
+	x.opt.Format = FormatText
+	x.opt.Verbose = false
+	x.opt.Debug = false
+	x.stat = newStat()
+	x.squash = newSquashBuffer()
+	x.links = newLinkTable()
+	x.ignores = newIgnoreTable(x.opt.GitIgnore)
+	x.progress = nil
+	x.opt.Progress = nil
+	x.lines, x.w, x.fn = nil, nil, nil
+	x.job = nil
+	x.listDir = false
+	return &x
+}
+
+func (h *Hasher) Options() Options {
+	return h.opt
+}
+
+// HashInput walks f and returns output lines which would be printed by
+// dirhash command.
+// The walk stops once ctx is done, in which case stats of entries walked so
+// far are printed, and the partial result is returned with ctx error.
+func (h *Hasher) HashInput(ctx context.Context, f string) (*Result, error) {
+	h.lines, h.w, h.fn = nil, nil, nil
+	return h.hashInput(ctx, f)
+}
+
+// PrintInput walks f and prints output lines to w as they are produced.
+func (h *Hasher) PrintInput(ctx context.Context, w io.Writer, f string) (*Result, error) {
+	h.lines, h.w, h.fn = nil, w, nil
+	return h.hashInput(ctx, f)
+}
+
+// WalkInput walks f and passes each hashed entry to fn instead of
+// formatting it. Result.Lines only contains non entry lines such as stats.
+func (h *Hasher) WalkInput(ctx context.Context, f string, fn EntryFunc) (*Result, error) {
+	h.lines, h.w, h.fn = nil, nil, fn
+	return h.hashInput(ctx, f)
+}
+
+func (h *Hasher) writeLine(s string) {
+	if h.w != nil {
+		if h.opt.Zero {
+			fmt.Fprint(h.w, s+"\x00")
+		} else {
+			fmt.Fprintln(h.w, s)
+		}
+	} else {
+		h.lines = append(h.lines, s)
+	}
+}
+
+func (h *Hasher) println(a ...interface{}) {
+	h.writeLine(strings.TrimSuffix(fmt.Sprintln(a...), "\n"))
+}
+
+func (h *Hasher) printf(format string, a ...interface{}) {
+	h.writeLine(strings.TrimSuffix(fmt.Sprintf(format, a...), "\n"))
+}
+
+func (h *Hasher) printNumFormatString(n uint, msg string) {
+	h.println(getNumFormatString(n, msg))
+}
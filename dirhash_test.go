@@ -0,0 +1,298 @@
+package dirhash
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_NewHasher(t *testing.T) {
+	validList := []Options{
+		{HashAlgo: SHA256},
+		{HashAlgo: "SHA256"},
+		{HashAlgo: MD5, HashVerify: "0xd41d8cd98f00b204e9800998ecf8427e"},
+	}
+	for _, opt := range validList {
+		if _, err := NewHasher(opt); err != nil {
+			t.Error(opt, err)
+		}
+	}
+
+	invalidList := []Options{
+		{},
+		{HashAlgo: "xxx"},
+		{HashAlgo: SHA256, HashVerify: "xxx"},
+	}
+	for _, opt := range invalidList {
+		if _, err := NewHasher(opt); err == nil {
+			t.Error(opt)
+		}
+	}
+}
+
+func newTestTree(t *testing.T) string {
+	d := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(d, "a/b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"a/x", "a/b/y", "z"} {
+		if err := os.WriteFile(filepath.Join(d, f), []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("a/x", filepath.Join(d, "l")); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func Test_HashInput(t *testing.T) {
+	d := newTestTree(t)
+	h, err := NewHasher(Options{HashAlgo: SHA256, Sort: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := h.HashInput(context.Background(), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Lines) != 4 {
+		t.Error(res.Lines)
+	}
+	_, b, _ := getStringHash("a/b/y", SHA256)
+	if s := getHexSum(b) + "  a/b/y"; res.Lines[0] != s {
+		t.Error(res.Lines[0], s)
+	}
+}
+
+func Test_WalkInput(t *testing.T) {
+	d := newTestTree(t)
+	h, err := NewHasher(Options{HashAlgo: SHA256, Sort: true,
+		FollowSymlink: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var l []*Entry
+	res, err := h.WalkInput(context.Background(), d, func(e *Entry) error {
+		l = append(l, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Lines) != 0 {
+		t.Error(res.Lines)
+	}
+
+	entryList := []struct {
+		path    string
+		typ     FileType
+		symlink string
+	}{
+		{"a/b/y", TypeReg, ""},
+		{"a/x", TypeReg, ""},
+		{"a/x", TypeReg, "l"},
+		{"z", TypeReg, ""},
+	}
+	if len(l) != len(entryList) {
+		t.Fatal(l)
+	}
+	for i, x := range entryList {
+		e := l[i]
+		if e.Path != x.path || e.Type != x.typ || e.Symlink != x.symlink {
+			t.Error(i, e)
+		}
+		if e.Written != uint64(len(x.path)) {
+			t.Error(i, e.Written)
+		}
+		_, b, _ := getStringHash(x.path, SHA256)
+		if getHexSum(e.Digest) != getHexSum(b) {
+			t.Error(i, e.Digest)
+		}
+	}
+}
+
+func Test_HashInputCanceled(t *testing.T) {
+	d := newTestTree(t)
+	h, err := NewHasher(Options{HashAlgo: SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, err := h.HashInput(ctx, d)
+	if !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+	if res == nil {
+		t.Error(res)
+	}
+}
+
+func Test_PrintInputZero(t *testing.T) {
+	d := t.TempDir()
+	if err := os.WriteFile(filepath.Join(d, "a\nb"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, b, _ := getStringHash("", SHA256)
+
+	for _, zero := range []bool{false, true} {
+		h, err := NewHasher(Options{HashAlgo: SHA256, Zero: zero})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var w bytes.Buffer
+		if _, err := h.PrintInput(context.Background(), &w, d); err != nil {
+			t.Fatal(err)
+		}
+		s := getHexSum(b) + "  a\nb\x00"
+		if !zero {
+			s = "\\" + getHexSum(b) + "  a\\nb\n"
+		}
+		if w.String() != s {
+			t.Errorf("%v %q", zero, w.String())
+		}
+	}
+}
+
+func Test_HashInputJobs(t *testing.T) {
+	d := newTestTree(t)
+	optList := []Options{
+		{HashAlgo: SHA256},
+		{HashAlgo: SHA256, Sort: true},
+		{HashAlgo: SHA256, FollowSymlink: true},
+		{HashAlgo: SHA256, Squash: true},
+		{HashAlgo: SHA256, Squash: true, FollowSymlink: true, Verbose: true},
+	}
+	for _, opt := range optList {
+		h, err := NewHasher(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res1, err := h.HashInput(context.Background(), d)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		opt.Jobs = 4
+		h, err = NewHasher(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res2, err := h.HashInput(context.Background(), d)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// output must be the same as serial walk
+		if !reflect.DeepEqual(res1, res2) {
+			t.Error(opt, res1, res2)
+		}
+	}
+
+	if _, err := NewHasher(Options{HashAlgo: SHA256, Jobs: -1}); err == nil {
+		t.Error("negative jobs")
+	}
+}
+
+func Test_HashInputIncludeExclude(t *testing.T) {
+	d := newTestTree(t)
+	optList := []struct {
+		include []string
+		exclude []string
+		n       uint
+	}{
+		{nil, nil, 4},
+		{[]string{"a"}, nil, 2},
+		{[]string{"**/y"}, nil, 1},
+		{nil, []string{"a/b"}, 3},
+		{nil, []string{"?/x", "l"}, 2},
+		{[]string{"a"}, []string{"**/x"}, 1},
+	}
+	for _, x := range optList {
+		h, err := NewHasher(Options{HashAlgo: SHA256, FollowSymlink: true,
+			Include: x.include, Exclude: x.exclude, Verbose: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := h.HashInput(context.Background(), d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := res.Stat.NumRegular; n != x.n {
+			t.Error(x, n)
+		}
+		if n := uint(len(res.Stat.Ignored)); n != 4-x.n {
+			t.Error(x, res.Stat.Ignored)
+		}
+	}
+
+	if _, err := NewHasher(Options{HashAlgo: SHA256, Exclude: []string{"["}}); err == nil {
+		t.Error("invalid pattern")
+	}
+}
+
+func Test_HashInputFilter(t *testing.T) {
+	d := newTestTree(t)
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, f := range []string{"z", "a/x", "a/b/y"} {
+		x := t0.Add(time.Duration(i) * time.Hour)
+		if err := os.Chtimes(filepath.Join(d, f), x, x); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	optList := []struct {
+		opt Options
+		l   []string
+	}{
+		{Options{Type: []FileType{TypeReg}}, []string{"a/b/y", "a/x", "z"}},
+		{Options{Type: []FileType{TypeSymlink}}, []string{"l"}},
+		{Options{Type: []FileType{TypeReg}, FollowSymlink: true}, []string{"a/b/y", "a/x", "l -> a/x", "z"}},
+		{Options{MinSize: 3}, []string{"a/b/y", "a/x", "l"}},
+		{Options{MaxSize: 3, Type: []FileType{TypeReg}}, []string{"a/x", "z"}},
+		{Options{MinSize: 2, MaxSize: 4}, []string{"a/x", "l"}},
+		{Options{Newer: t0, Type: []FileType{TypeReg}}, []string{"a/b/y", "a/x"}},
+		{Options{Older: t0.Add(2 * time.Hour), Type: []FileType{TypeReg}}, []string{"a/x", "z"}},
+		{Options{Newer: t0, Older: t0.Add(2 * time.Hour), FollowSymlink: true}, []string{"a/x", "l -> a/x"}},
+	}
+	for _, x := range optList {
+		opt := x.opt
+		opt.HashAlgo = SHA256
+		opt.Sort = true
+		h, err := NewHasher(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := h.HashInput(context.Background(), d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var l []string
+		for _, s := range res.Lines {
+			l = append(l, strings.SplitN(s, "  ", 2)[1])
+		}
+		if !reflect.DeepEqual(l, x.l) {
+			t.Error(x.opt, l)
+		}
+	}
+
+	for _, opt := range []Options{
+		{HashAlgo: SHA256, Type: []FileType{TypeInvalid}},
+		{HashAlgo: SHA256, MinSize: -1},
+		{HashAlgo: SHA256, MaxSize: -1},
+	} {
+		if _, err := NewHasher(opt); err == nil {
+			t.Error(opt)
+		}
+	}
+}
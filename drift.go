@@ -0,0 +1,139 @@
+package dirhash
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DriftInput compares manifest r, which is output of dirhash against input
+// f, with current entries of f. Unlike DiffInput, paths are relative to
+// input prefix as printed, and type of manifest entries is only known as
+// whether or not it is a followed symlink.
+// Squash lines and improperly formatted lines in r are ignored, and so are
+// manifest entries ignored by options of h. Tagged lines of other hash
+// algorithm are an error.
+func (h *Hasher) DriftInput(ctx context.Context, f string, r io.Reader) (*DiffResult, error) {
+	f, err := h.initInput(f)
+	if err != nil {
+		return nil, err
+	} else if len(f) == 0 {
+		return nil, errors.New("no input to compare")
+	}
+
+	// read manifest
+	x := h.newSubHasher()
+	x.opt.Abs = false
+	m1 := make(map[string]*Entry)
+	scanner := h.newLineScanner(r)
+	for scanner.Scan() {
+		e, err := h.parseLine(scanner.Text())
+		if err != nil || e.Squash {
+			continue
+		}
+		if len(e.HashAlgo) != 0 && e.HashAlgo != h.getHashAlgoLabel() {
+			return nil, fmt.Errorf("%s: hash algorithm %s mismatch",
+				e.Path, e.HashAlgo)
+		}
+		k, v := x.getManifestEntry(e)
+		m1[k] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// walk current entries
+	m2 := make(map[string]*Entry)
+	if _, err := x.WalkInput(ctx, f, func(e *Entry) error {
+		if len(e.Symlink) > 0 {
+			m2[e.Symlink] = e
+		} else {
+			m2[e.Path] = e
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	var l []string
+	for k := range m1 {
+		l = append(l, k)
+	}
+	for k := range m2 {
+		if _, ok := m1[k]; !ok {
+			l = append(l, k)
+		}
+	}
+	sort.Strings(l)
+
+	res := &DiffResult{}
+	for _, k := range l {
+		e1, ok1 := m1[k]
+		e2, ok2 := m2[k]
+		d := &DiffEntry{Path: k, Old: e1, New: e2}
+		if !ok1 {
+			d.Status = DiffAdded
+		} else if !ok2 {
+			// not hashed if type changed to e.g. directory, or if ignored
+			if t, err := getRawFileType(h.fs, getCheckPath(x.inputPrefix, k)); err != nil {
+				d.Status = DiffRemoved
+			} else if t == TypeReg || t == TypeDevice || t == TypeSymlink {
+				continue
+			} else {
+				d.Status = DiffTypeChanged
+				d.New = &Entry{Path: k, Type: t}
+			}
+		} else if (len(e1.Symlink) > 0) != (len(e2.Symlink) > 0) {
+			d.Status = DiffTypeChanged
+		} else if getHexSum(e1.Digest) != getHexSum(e2.Digest) || e1.Path != e2.Path {
+			d.Status = DiffModified
+		} else {
+			continue
+		}
+		res.Entries = append(res.Entries, d)
+	}
+
+	return res, nil
+}
+
+// getManifestEntry returns key and entry of manifest entry e, with paths
+// converted to the format of getRealPath without Options.Abs.
+func (h *Hasher) getManifestEntry(e *ListEntry) (string, *Entry) {
+	b, err := hex.DecodeString(e.HexSum)
+	if err != nil {
+		b = nil
+	}
+	v := &Entry{
+		Path:   e.Path,
+		Type:   TypeReg, // unknown
+		Digest: b,
+	}
+
+	// followed symlink is printed in link -> target format
+	if _, err := h.fs.lstat(getCheckPath(h.inputPrefix, v.Path)); err != nil {
+		if l := strings.SplitN(v.Path, " -> ", 2); len(l) == 2 {
+			v.Symlink, v.Path = l[0], l[1]
+		}
+	}
+
+	v.Path = h.getManifestPath(v.Path)
+	if len(v.Symlink) > 0 {
+		v.Symlink = h.getManifestPath(v.Symlink)
+		return v.Symlink, v
+	} else {
+		return v.Path, v
+	}
+}
+
+func (h *Hasher) getManifestPath(f string) string {
+	if filepath.IsAbs(f) {
+		return h.getRealPath(f)
+	} else {
+		return f
+	}
+}
@@ -0,0 +1,140 @@
+package dirhash
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_DriftInput(t *testing.T) {
+	for _, abs := range []bool{false, true} {
+		d := newTestTree(t)
+		h, err := NewHasher(Options{HashAlgo: SHA256, FollowSymlink: true,
+			Abs: abs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := h.HashInput(context.Background(), d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := strings.Join(res.Lines, "\n")
+
+		ret, err := h.DriftInput(context.Background(), d, strings.NewReader(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ret.Entries) != 0 {
+			t.Error(abs, ret.Entries)
+		}
+
+		if err := os.WriteFile(filepath.Join(d, "a/x"), []byte("xxx"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Remove(filepath.Join(d, "a/b/y")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Remove(filepath.Join(d, "z")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Mkdir(filepath.Join(d, "z"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(d, "w"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		ret, err = h.DriftInput(context.Background(), d, strings.NewReader(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		driftList := []struct {
+			path   string
+			status DiffStatus
+		}{
+			{"a/b/y", DiffRemoved},
+			{"a/x", DiffModified},
+			{"l", DiffModified},
+			{"w", DiffAdded},
+			{"z", DiffTypeChanged},
+		}
+		if len(ret.Entries) != len(driftList) {
+			t.Fatal(abs, ret.Entries)
+		}
+		for i, x := range driftList {
+			if e := ret.Entries[i]; e.Path != x.path || e.Status != x.status {
+				t.Error(abs, i, e)
+			}
+		}
+		if e := ret.Entries[4]; e.New.Type != TypeDir {
+			t.Error(abs, e.New)
+		}
+	}
+}
+
+func Test_DriftInputIgnored(t *testing.T) {
+	d := newTestTree(t)
+	if err := os.WriteFile(filepath.Join(d, ".x.pyc"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHasher(Options{HashAlgo: SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := h.HashInput(context.Background(), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Join(res.Lines, "\n")
+
+	// entries ignored by options are not drift
+	optList := []Options{
+		{IgnoreDotFile: true},
+		{Exclude: []string{"*.pyc"}},
+		{IgnoreSymlink: true},
+		{Type: []FileType{TypeSymlink}},
+	}
+	for _, opt := range optList {
+		opt.HashAlgo = SHA256
+		h, err := NewHasher(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ret, err := h.DriftInput(context.Background(), d, strings.NewReader(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ret.Entries) != 0 {
+			t.Error(opt, ret.Entries)
+		}
+	}
+}
+
+func Test_DriftInputHashAlgo(t *testing.T) {
+	d := newTestTree(t)
+	h, err := NewHasher(Options{HashAlgo: SHA256, Tag: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := h.HashInput(context.Background(), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Join(res.Lines, "\n")
+
+	if ret, err := h.DriftInput(context.Background(), d, strings.NewReader(s)); err != nil {
+		t.Fatal(err)
+	} else if len(ret.Entries) != 0 {
+		t.Error(ret.Entries)
+	}
+
+	h, err = NewHasher(Options{HashAlgo: MD5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.DriftInput(context.Background(), d, strings.NewReader(s)); err == nil {
+		t.Error("hash algorithm mismatch")
+	}
+}
@@ -0,0 +1,177 @@
+package dirhash
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ReadLinkFS is an optional extension of fs.FS which exposes symlinks.
+// Without it, fs.FS entries are walked as they are reported by fs.Stat.
+// The method set matches fs.ReadLinkFS of Go 1.25.
+type ReadLinkFS interface {
+	fs.FS
+	ReadLink(name string) (string, error)
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+// fileSystem takes absolute paths regardless of implementation.
+type fileSystem interface {
+	lstat(f string) (fs.FileInfo, error)
+	stat(f string) (fs.FileInfo, error)
+	open(f string) (fs.File, error)
+	evalSymlinks(f string) (string, error)
+	walkDir(f string, fn fs.WalkDirFunc) error
+	readDir(f string) ([]fs.DirEntry, error)
+	readLink(f string) (string, error)
+	abs(f string) (string, error)
+}
+
+// hostFS is the host filesystem.
+type hostFS struct{}
+
+func (hostFS) lstat(f string) (fs.FileInfo, error) {
+	return os.Lstat(f)
+}
+
+func (hostFS) stat(f string) (fs.FileInfo, error) {
+	return os.Stat(f)
+}
+
+func (hostFS) open(f string) (fs.File, error) {
+	return openFile(f)
+}
+
+func (hostFS) evalSymlinks(f string) (string, error) {
+	return filepath.EvalSymlinks(f)
+}
+
+func (hostFS) walkDir(f string, fn fs.WalkDirFunc) error {
+	return filepath.WalkDir(f, fn)
+}
+
+func (hostFS) readDir(f string) ([]fs.DirEntry, error) {
+	return os.ReadDir(f)
+}
+
+func (hostFS) readLink(f string) (string, error) {
+	return os.Readlink(f)
+}
+
+func (hostFS) abs(f string) (string, error) {
+	return filepath.Abs(f)
+}
+
+// ioFS is fs.FS with its root mapped to "/".
+// Absolute symlink targets are resolved relative to the root.
+type ioFS struct {
+	fsys fs.FS
+}
+
+func newIOFS(fsys fs.FS) *ioFS {
+	return &ioFS{fsys: fsys}
+}
+
+func (x *ioFS) name(f string) string {
+	if s := strings.TrimPrefix(path.Clean("/"+f), "/"); len(s) == 0 {
+		return "."
+	} else {
+		return s
+	}
+}
+
+func (x *ioFS) lstat(f string) (fs.FileInfo, error) {
+	if l, ok := x.fsys.(ReadLinkFS); ok {
+		return l.Lstat(x.name(f))
+	} else {
+		return fs.Stat(x.fsys, x.name(f))
+	}
+}
+
+func (x *ioFS) stat(f string) (fs.FileInfo, error) {
+	if _, ok := x.fsys.(ReadLinkFS); ok {
+		s, err := x.evalSymlinks(f)
+		if err != nil {
+			return nil, err
+		}
+		return x.lstat(s)
+	} else {
+		return fs.Stat(x.fsys, x.name(f))
+	}
+}
+
+func (x *ioFS) open(f string) (fs.File, error) {
+	return x.fsys.Open(x.name(f))
+}
+
+func (x *ioFS) evalSymlinks(f string) (string, error) {
+	l, ok := x.fsys.(ReadLinkFS)
+	if !ok {
+		if _, err := x.lstat(f); err != nil {
+			return "", err
+		}
+		return path.Join("/", x.name(f)), nil
+	}
+
+	// resolve each component, same as filepath.EvalSymlinks
+	resolved := "/"
+	rest := strings.Split(x.name(f), "/")
+	links := 0
+	for len(rest) > 0 {
+		c := rest[0]
+		rest = rest[1:]
+		switch c {
+		case "", ".":
+			continue
+		case "..":
+			resolved = path.Dir(resolved)
+			continue
+		}
+		next := path.Join(resolved, c)
+		info, err := l.Lstat(x.name(next))
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		if links++; links > 255 {
+			return "", errors.New("EvalSymlinks: too many links")
+		}
+		target, err := l.ReadLink(x.name(next))
+		if err != nil {
+			return "", err
+		}
+		if path.IsAbs(target) {
+			resolved = "/"
+		}
+		rest = append(strings.Split(target, "/"), rest...)
+	}
+	return resolved, nil
+}
+
+func (x *ioFS) walkDir(f string, fn fs.WalkDirFunc) error {
+	return fs.WalkDir(x.fsys, x.name(f),
+		func(f string, d fs.DirEntry, err error) error {
+			return fn(path.Join("/", f), d, err)
+		})
+}
+
+func (x *ioFS) readDir(f string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(x.fsys, x.name(f))
+}
+
+func (x *ioFS) readLink(f string) (string, error) {
+	if l, ok := x.fsys.(ReadLinkFS); ok {
+		return l.ReadLink(x.name(f))
+	}
+	return "", &fs.PathError{Op: "readlink", Path: f, Err: fs.ErrInvalid}
+}
+
+func (x *ioFS) abs(f string) (string, error) {
+	return path.Join("/", f), nil
+}
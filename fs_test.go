@@ -0,0 +1,98 @@
+package dirhash
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func Test_ioFSName(t *testing.T) {
+	nameList := []struct {
+		i string
+		o string
+	}{
+		{"", "."},
+		{".", "."},
+		{"/", "."},
+		{"a", "a"},
+		{"/a", "a"},
+		{"/a/", "a"},
+		{"/a/../b", "b"},
+		{"/..", "."},
+		{".a", ".a"},
+	}
+	x := newIOFS(fstest.MapFS{})
+	for _, v := range nameList {
+		if s := x.name(v.i); s != v.o {
+			t.Error(v, s)
+		}
+	}
+}
+
+var (
+	fsOptionsList = []Options{
+		{HashAlgo: SHA256},
+		{HashAlgo: SHA256, Squash: true},
+		{HashAlgo: SHA256, Squash: true, Verbose: true},
+		{HashAlgo: SHA256, FollowSymlink: true},
+		{HashAlgo: SHA256, FollowSymlink: true, Squash: true},
+	}
+)
+
+func hashInputLines(t *testing.T, opt Options, f string) []string {
+	h, err := NewHasher(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := h.HashInput(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.Lines
+}
+
+func Test_MapFS(t *testing.T) {
+	d := newTestTree(t)
+	if err := os.Remove(d + "/l"); err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{
+		"a/x":   {Data: []byte("a/x")},
+		"a/b/y": {Data: []byte("a/b/y")},
+		"z":     {Data: []byte("z")},
+	}
+
+	for _, opt := range fsOptionsList {
+		l1 := hashInputLines(t, opt, d)
+		opt.FS = fsys
+		l2 := hashInputLines(t, opt, ".")
+		if !reflect.DeepEqual(l1, l2) {
+			t.Error(opt, l1, l2)
+		}
+	}
+
+	opt := Options{HashAlgo: SHA256, FS: fsys}
+	l1 := hashInputLines(t, opt, "a/x")
+	l2 := hashInputLines(t, opt, "/a/x")
+	if len(l1) != 1 || !reflect.DeepEqual(l1, l2) {
+		t.Error(l1, l2)
+	}
+}
+
+func Test_DirFS(t *testing.T) {
+	d := newTestTree(t)
+	if _, ok := os.DirFS(d).(ReadLinkFS); !ok {
+		t.Skip("os.DirFS does not support symlinks")
+	}
+
+	for _, opt := range fsOptionsList {
+		l1 := hashInputLines(t, opt, d)
+		opt.FS = os.DirFS(d)
+		l2 := hashInputLines(t, opt, ".")
+		if !reflect.DeepEqual(l1, l2) {
+			t.Error(opt, l1, l2)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package dirhash
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// globPattern is a glob pattern of slash separated path, where "**" as a
+// path component matches zero or more path components, and other
+// components are matched by path.Match.
+type globPattern struct {
+	s string
+	l []string
+}
+
+func newGlobPattern(s string) (*globPattern, error) {
+	x := strings.TrimPrefix(path.Clean("/"+s), "/")
+	if len(x) == 0 {
+		return nil, fmt.Errorf("invalid pattern %q", s)
+	}
+	l := strings.Split(x, "/")
+	for _, v := range l {
+		if _, err := path.Match(v, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q", s)
+		}
+	}
+	return &globPattern{s: s, l: l}, nil
+}
+
+func newGlobPatternList(l []string) ([]*globPattern, error) {
+	var ret []*globPattern
+	for _, s := range l {
+		p, err := newGlobPattern(s)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, p)
+	}
+	return ret, nil
+}
+
+// match returns true if slash separated relative path f matches p.
+func (p *globPattern) match(f string) bool {
+	return matchGlob(p.l, strings.Split(f, "/"))
+}
+
+func matchGlob(pl []string, fl []string) bool {
+	for len(pl) > 0 {
+		if pl[0] == "**" {
+			for i := 0; i <= len(fl); i++ {
+				if matchGlob(pl[1:], fl[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(fl) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pl[0], fl[0]); !ok {
+			return false
+		}
+		pl, fl = pl[1:], fl[1:]
+	}
+	return len(fl) == 0
+}
+
+// matchGlobPatternList returns true if relative path f or any of its parent
+// directories matches any of l.
+func matchGlobPatternList(l []*globPattern, f string) bool {
+	for _, p := range l {
+		for x := f; ; {
+			if p.match(x) {
+				return true
+			}
+			i := strings.LastIndex(x, "/")
+			if i == -1 {
+				break
+			}
+			x = x[:i]
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package dirhash
+
+import (
+	"testing"
+)
+
+func Test_globPattern(t *testing.T) {
+	matchList := []struct {
+		p string
+		f string
+		m bool
+	}{
+		{"a", "a", true},
+		{"a", "b/a", false},
+		{"*.pyc", "a.pyc", true},
+		{"*.pyc", "b/a.pyc", false},
+		{"**/*.pyc", "a.pyc", true},
+		{"**/*.pyc", "b/c/a.pyc", true},
+		{"**/node_modules/**", "node_modules/x", true},
+		{"**/node_modules/**", "a/node_modules/b/x", true},
+		{"**/node_modules/**", "a/node_modules", true},
+		{"**/node_modules/**", "a/node_modules_x/b", false},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/b", "a/x/y/c", false},
+		{"a/*/b", "a/x/y/b", false},
+		{"a?/[bc]", "ax/c", true},
+		{"/a/b/", "a/b", true},
+		{"./a", "a", true},
+		{"**", "a/b", true},
+	}
+	for _, x := range matchList {
+		p, err := newGlobPattern(x.p)
+		if err != nil {
+			t.Fatal(x, err)
+		}
+		if m := p.match(x.f); m != x.m {
+			t.Error(x, m)
+		}
+	}
+
+	for _, s := range []string{"", "/", "[", "a/[/b"} {
+		if _, err := newGlobPattern(s); err == nil {
+			t.Error(s)
+		}
+	}
+}
+
+func Test_matchGlobPatternList(t *testing.T) {
+	l, err := newGlobPatternList([]string{"**/build", "x/*.o"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	matchList := []struct {
+		f string
+		m bool
+	}{
+		{"build", true},
+		{"build/a", true},
+		{"a/build/b/c", true},
+		{"a/builder/b", false},
+		{"x/a.o", true},
+		{"x/a.o/b", true},
+		{"x/y/a.o", false},
+	}
+	for _, x := range matchList {
+		if m := matchGlobPatternList(l, x.f); m != x.m {
+			t.Error(x, m)
+		}
+	}
+}
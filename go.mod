@@ -4,4 +4,4 @@ go 1.18
 
 require golang.org/x/crypto v0.26.0
 
-require golang.org/x/sys v0.24.0 // indirect
+require golang.org/x/sys v0.24.0
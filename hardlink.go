@@ -0,0 +1,64 @@
+package dirhash
+
+import (
+	"sync"
+)
+
+// fileKey identifies a file regardless of its path.
+type fileKey struct {
+	dev uint64
+	ino uint64
+}
+
+func (st *fileStat) getKey() fileKey {
+	return fileKey{st.dev, st.ino}
+}
+
+type linkEntry struct {
+	done chan struct{}
+	hash *fileHash
+	err  error
+}
+
+// linkTable hashes a hardlinked inode once per walk, while later links wait
+// for the first one to be hashed. linkTable is safe for concurrent use.
+type linkTable struct {
+	mtx sync.Mutex
+	m   map[fileKey]*linkEntry
+}
+
+func newLinkTable() *linkTable {
+	return &linkTable{
+		m: make(map[fileKey]*linkEntry),
+	}
+}
+
+func (t *linkTable) get(k fileKey, fn func() (*fileHash, error)) (*fileHash, error) {
+	t.mtx.Lock()
+	e, ok := t.m[k]
+	if !ok {
+		e = &linkEntry{done: make(chan struct{})}
+		t.m[k] = e
+	}
+	t.mtx.Unlock()
+
+	if ok {
+		<-e.done
+		if e.err == nil {
+			x := *e.hash
+			x.mismatch = false // reported by the first one
+			return &x, nil
+		}
+		return fn()
+	}
+
+	e.hash, e.err = fn()
+	if e.err != nil {
+		// let later links hash by themselves
+		t.mtx.Lock()
+		delete(t.m, k)
+		t.mtx.Unlock()
+	}
+	close(e.done)
+	return e.hash, e.err
+}
@@ -0,0 +1,93 @@
+package dirhash
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_linkTable(t *testing.T) {
+	tbl := newLinkTable()
+	k := fileKey{1, 2}
+	var n int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			x, err := tbl.get(k, func() (*fileHash, error) {
+				atomic.AddInt32(&n, 1)
+				return &fileHash{written: 1, b: []byte{1}, mismatch: true}, nil
+			})
+			if err != nil || x.written != 1 {
+				t.Error(x, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// inode is hashed once
+	if n != 1 {
+		t.Error(n)
+	}
+}
+
+func Test_HashInputHardlink(t *testing.T) {
+	d := t.TempDir()
+	if err := os.WriteFile(filepath.Join(d, "a"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(d, "a"), filepath.Join(d, "b")); err != nil {
+		t.Skip(err)
+	}
+	info, err := os.Stat(filepath.Join(d, "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if getFileStat(info) == nil {
+		t.Skip("file stat unavailable")
+	}
+
+	for _, jobs := range []int{0, 2} {
+		h, err := NewHasher(Options{HashAlgo: SHA256, ReportHardlink: true,
+			Jobs: jobs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := h.HashInput(context.Background(), d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Lines) != 4 || res.Lines[2] != "1 hardlinked file" ||
+			res.Lines[3] != "b (hardlink to a)" {
+			t.Error(jobs, res.Lines)
+		}
+		if len(res.Stat.Hardlink) != 1 || res.Stat.Hardlink["b"] != "a" {
+			t.Error(jobs, res.Stat.Hardlink)
+		}
+	}
+
+	// squash once is the same as squash without later links
+	h, err := NewHasher(Options{HashAlgo: SHA256, Squash: true,
+		SquashHardlinkOnce: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res1, err := h.HashInput(context.Background(), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(d, "b")); err != nil {
+		t.Fatal(err)
+	}
+	res2, err := h.HashInput(context.Background(), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if getHexSum(res1.Squash) != getHexSum(res2.Squash) {
+		t.Error(res1.Lines, res2.Lines)
+	}
+}
@@ -1,7 +1,8 @@
-package main
+package dirhash
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
@@ -11,8 +12,11 @@ import (
 	"golang.org/x/crypto/sha3"
 	"hash"
 	"io"
-	"os"
+	"io/fs"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 const (
@@ -30,7 +34,7 @@ const (
 	SHA3_512   = "sha3_512"
 )
 
-func getAvailableHashAlgo() []string {
+func GetAvailableHashAlgo() []string {
 	return []string{
 		MD5,
 		SHA1,
@@ -47,7 +51,52 @@ func getAvailableHashAlgo() []string {
 	}
 }
 
-func newHash(hashAlgo string) hash.Hash {
+// treeHashSuffix and chunk size are appended to hash algorithm of files in
+// tree hash mode, e.g. "sha256-tree-4096".
+const treeHashSuffix = "-tree"
+
+// getHashAlgoTag returns algorithm name used by shaXsum --tag and BSD
+// commands.
+func getHashAlgoTag(hashAlgo string) string {
+	if s, n, ok := strings.Cut(hashAlgo, treeHashSuffix+"-"); ok {
+		return getHashAlgoTag(s) + strings.ToUpper(treeHashSuffix) + "-" + n
+	}
+
+	switch hashAlgo {
+	case SHA512_224:
+		return "SHA512/224"
+	case SHA512_256:
+		return "SHA512/256"
+	case SHA3_224, SHA3_256, SHA3_384, SHA3_512:
+		return strings.ToUpper(strings.Replace(hashAlgo, "_", "-", 1))
+	default:
+		return strings.ToUpper(hashAlgo)
+	}
+}
+
+func getHashAlgoFromTag(s string) string {
+	if x, n, ok := strings.Cut(s, strings.ToUpper(treeHashSuffix)+"-"); ok {
+		if algo := getHashAlgoFromTag(x); len(algo) != 0 && isValidChunkSize(n) {
+			return algo + treeHashSuffix + "-" + n
+		}
+		return ""
+	}
+
+	for _, x := range GetAvailableHashAlgo() {
+		if getHashAlgoTag(x) == s {
+			return x
+		}
+	}
+	return ""
+}
+
+// isValidChunkSize returns true if s is chunk size of tree hash label.
+func isValidChunkSize(s string) bool {
+	n, err := strconv.ParseInt(s, 10, 64)
+	return err == nil && n > 0 && strconv.FormatInt(n, 10) == s
+}
+
+func NewHash(hashAlgo string) hash.Hash {
 	switch hashAlgo {
 	case MD5:
 		return md5.New()
@@ -78,23 +127,219 @@ func newHash(hashAlgo string) hash.Hash {
 	}
 }
 
-func getFileHash(f string, hashAlgo string) (uint64, []byte, error) {
-	fp, err := os.Open(f)
+// fileHash is hash of a file.
+type fileHash struct {
+	written  uint64
+	b        []byte
+	skipped  uint64    // bytes of holes read without I/O
+	st       *fileStat // nil unless available regular file
+	mismatch bool      // cache hit re-hashed by Options.CacheVerify differs
+}
+
+func getFileHash(ctx context.Context, fsys fileSystem, f string, hashAlgo string,
+	p *progress, l *rateLimiter) (*fileHash, error) {
+	fp, err := fsys.open(f)
 	if err != nil {
-		return 0, nil, err
+		return nil, err
 	}
 	defer fp.Close()
+	defer dropFileCache(fp)
 
 	info, err := fp.Stat()
 	if err != nil {
-		return 0, nil, err
+		return nil, err
 	}
 	statSize := uint64(info.Size())
 
-	written, b, err := getHash(fp, hashAlgo)
+	// read holes of regular file as zeros without I/O
+	r := throttleReader(ctx, fp, l)
+	var sr *sparseReader
+	if info.Mode().IsRegular() {
+		if sr = newSparseReader(fp, info.Size()); sr != nil {
+			sr.ra = throttleReaderAt(ctx, sr.ra, l)
+			r = sr
+		}
+	}
+
+	written, b, err := getHash(&contextReader{ctx, r, p}, hashAlgo)
+	if err != nil {
+		return nil, err
+	}
 	assert(written == statSize || statSize == 0)
 
-	return written, b, err
+	x := &fileHash{written: written, b: b}
+	if sr != nil {
+		x.skipped = sr.skipped
+	}
+	return x, nil
+}
+
+// getHashAlgoLabel returns hash algorithm of files, which differs from
+// Options.HashAlgo in tree hash mode as the digest depends on chunk size.
+func (h *Hasher) getHashAlgoLabel() string {
+	if h.opt.ChunkSize > 0 {
+		return fmt.Sprintf("%s%s-%d", h.opt.HashAlgo, treeHashSuffix, h.opt.ChunkSize)
+	}
+	return h.opt.HashAlgo
+}
+
+// HashAlgoLabel returns hash algorithm of files as printed, e.g.
+// "sha256-tree-4096" in tree hash mode.
+func (h *Hasher) HashAlgoLabel() string {
+	return h.getHashAlgoLabel()
+}
+
+// readFileHash returns hash of f, which is tree hash if Options.ChunkSize
+// is set.
+func (h *Hasher) readFileHash(ctx context.Context, f string) (*fileHash, error) {
+	if h.opt.ChunkSize > 0 {
+		written, b, err := getFileTreeHash(ctx, h.fs, f, h.opt.HashAlgo, h.opt.ChunkSize,
+			h.progress, h.limiter)
+		if err != nil {
+			return nil, err
+		}
+		return &fileHash{written: written, b: b}, nil
+	}
+	return getFileHash(ctx, h.fs, f, h.opt.HashAlgo, h.progress, h.limiter)
+}
+
+// getFileTreeHash returns tree hash of f, which is message digest of
+// concatenated message digests of chunkSize byte chunks of f in file
+// order. Empty f has a single empty chunk.
+// Chunks are hashed concurrently if f supports io.ReaderAt and its size is
+// known, otherwise sequentially.
+func getFileTreeHash(ctx context.Context, fsys fileSystem, f string, hashAlgo string,
+	chunkSize int64, p *progress, rl *rateLimiter) (uint64, []byte, error) {
+	assert(chunkSize > 0)
+	fp, err := fsys.open(f)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer fp.Close()
+	defer dropFileCache(fp)
+
+	size, err := getFileSize(fp)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var l [][]byte
+	var written uint64
+	if ra, ok := fp.(io.ReaderAt); ok && size > 0 {
+		ra = throttleReaderAt(ctx, ra, rl)
+		l, err = getChunkHashAt(ctx, ra, size, hashAlgo, chunkSize, p)
+		written = uint64(size)
+	} else {
+		r := throttleReader(ctx, fp, rl)
+		l, written, err = getChunkHash(ctx, r, hashAlgo, chunkSize, p)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	_, b, err := getByteHash(bytes.Join(l, nil), hashAlgo)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return written, b, nil
+}
+
+// getFileSize returns size of fp, which is 0 if unknown.
+func getFileSize(fp fs.File) (int64, error) {
+	info, err := fp.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if info.Mode().IsRegular() {
+		return info.Size(), nil
+	}
+
+	// device size is only known by seek
+	if s, ok := fp.(io.Seeker); ok {
+		size, err := s.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, nil
+		}
+		if _, err := s.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		return size, nil
+	}
+	return 0, nil
+}
+
+func getChunkHashAt(ctx context.Context, ra io.ReaderAt, size int64, hashAlgo string,
+	chunkSize int64, p *progress) ([][]byte, error) {
+	n := (size + chunkSize - 1) / chunkSize
+	l := make([][]byte, n)
+	errl := make([]error, n)
+
+	ch := make(chan int64)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range ch {
+				off := i * chunkSize
+				n := chunkSize
+				if off+n > size {
+					n = size - off
+				}
+				r := io.NewSectionReader(ra, off, n)
+				written, b, err := getHash(&contextReader{ctx, r, p}, hashAlgo)
+				if err == nil && written != uint64(n) {
+					err = fmt.Errorf("short read at offset %d", off)
+				}
+				l[i], errl[i] = b, err
+			}
+		}()
+	}
+	for i := int64(0); i < n; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+		ch <- i
+	}
+	close(ch)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for _, err := range errl {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func getChunkHash(ctx context.Context, r io.Reader, hashAlgo string,
+	chunkSize int64, p *progress) ([][]byte, uint64, error) {
+	var l [][]byte
+	var written uint64
+	r = &contextReader{ctx, r, p}
+	for {
+		h := NewHash(hashAlgo)
+		if h == nil {
+			return nil, 0, fmt.Errorf("invalid hash algorithm %s", hashAlgo)
+		}
+		n, err := io.CopyN(h, r, chunkSize)
+		if err != nil && err != io.EOF {
+			return nil, 0, err
+		}
+		// last chunk is empty if f size is multiple of chunk size
+		if n > 0 || len(l) == 0 {
+			l = append(l, h.Sum(nil))
+		}
+		written += uint64(n)
+		if err == io.EOF {
+			break
+		}
+	}
+	return l, written, nil
 }
 
 func getByteHash(s []byte, hashAlgo string) (uint64, []byte, error) {
@@ -116,7 +361,7 @@ func getStringHash(s string, hashAlgo string) (uint64, []byte, error) {
 }
 
 func getHash(r io.Reader, hashAlgo string) (uint64, []byte, error) {
-	h := newHash(hashAlgo)
+	h := NewHash(hashAlgo)
 	if h == nil {
 		return 0, nil, fmt.Errorf("invalid hash algorithm %s", hashAlgo)
 	}
@@ -129,6 +374,23 @@ func getHash(r io.Reader, hashAlgo string) (uint64, []byte, error) {
 	return uint64(written), h.Sum(nil), nil
 }
 
+// contextReader fails read once ctx is done, so io.Copy is interruptible.
+// Bytes read are counted by progress if set.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+	p   *progress
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := r.r.Read(p)
+	r.p.addByte(uint64(n))
+	return n, err
+}
+
 func getHexSum(sum []byte) string {
 	return hex.EncodeToString(sum)
 }
@@ -1,14 +1,18 @@
-package main
+package dirhash
 
 import (
 	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
 
-func Test_newHash(t *testing.T) {
-	for _, s := range getAvailableHashAlgo() {
-		if h := newHash(s); h == nil {
+func Test_NewHash(t *testing.T) {
+	for _, s := range GetAvailableHashAlgo() {
+		if h := NewHash(s); h == nil {
 			t.Error(s)
 		}
 	}
@@ -19,7 +23,7 @@ func Test_newHash(t *testing.T) {
 		"SHA256",
 		"516e7cb4-6ecf-11d6-8ff8-00022d09712b"}
 	for _, s := range invalidList {
-		if h := newHash(s); h != nil {
+		if h := NewHash(s); h != nil {
 			t.Error(s)
 		}
 	}
@@ -53,6 +57,41 @@ var (
 	algSumList2Repeat = 1000000
 )
 
+func Test_getHashAlgoTag(t *testing.T) {
+	tagList := []struct {
+		algo string
+		tag  string
+	}{
+		{MD5, "MD5"},
+		{SHA256, "SHA256"},
+		{SHA512_224, "SHA512/224"},
+		{SHA512_256, "SHA512/256"},
+		{SHA3_256, "SHA3-256"},
+		{SHA256 + treeHashSuffix + "-4096", "SHA256-TREE-4096"},
+		{SHA3_256 + treeHashSuffix + "-1", "SHA3-256-TREE-1"},
+	}
+	for _, x := range tagList {
+		if s := getHashAlgoTag(x.algo); s != x.tag {
+			t.Error(x, s)
+		}
+		if s := getHashAlgoFromTag(x.tag); s != x.algo {
+			t.Error(x, s)
+		}
+	}
+	for _, algo := range GetAvailableHashAlgo() {
+		if s := getHashAlgoFromTag(getHashAlgoTag(algo)); s != algo {
+			t.Error(algo, s)
+		}
+	}
+
+	// chunk size is required
+	for _, s := range []string{"SHA256-TREE", "SHA256-TREE-", "SHA256-TREE-0", "SHA256-TREE-01", "XXX-TREE-1"} {
+		if x := getHashAlgoFromTag(s); len(x) != 0 {
+			t.Error(s, x)
+		}
+	}
+}
+
 func Test_getByteHash(t *testing.T) {
 	for _, x := range algSumList1 {
 		written, sum, err := getByteHash([]byte{}, x.hashAlgo)
@@ -110,3 +149,43 @@ func Test_getStringHash(t *testing.T) {
 		}
 	}
 }
+
+func Test_getFileTreeHash(t *testing.T) {
+	d := t.TempDir()
+	for _, size := range []int{0, 1, 4095, 4096, 4097, 3*4096 + 1} {
+		b := bytes.Repeat([]byte("x"), size)
+		f := filepath.Join(d, fmt.Sprint(size))
+		if err := os.WriteFile(f, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		// root is digest of concatenated chunk digests
+		var l []byte
+		for i := 0; i == 0 || i < size; i += 4096 {
+			n := 4096
+			if i+n > size {
+				n = size - i
+			}
+			_, x, _ := getByteHash(b[i:i+n], SHA256)
+			l = append(l, x...)
+		}
+		_, root, _ := getByteHash(l, SHA256)
+
+		written, x, err := getFileTreeHash(context.Background(), hostFS{}, f, SHA256, 4096, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if written != uint64(size) || !bytes.Equal(x, root) {
+			t.Error(size, written, getHexSum(x))
+		}
+
+		// sequential read results in the same chunks
+		cl, written, err := getChunkHash(context.Background(), bytes.NewReader(b), SHA256, 4096, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if written != uint64(size) || !bytes.Equal(bytes.Join(cl, nil), l) {
+			t.Error(size, written, cl)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package dirhash
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+const (
+	gitIgnoreFile     = ".gitignore"
+	dirhashIgnoreFile = ".dirhashignore"
+)
+
+// ignoreRule is a line of ignore file in gitignore format.
+type ignoreRule struct {
+	p      *globPattern
+	negate bool
+	dir    bool // only matches directory
+}
+
+// parseIgnoreRules parses ignore file content s in gitignore format, where
+// invalid patterns are skipped same as git.
+func parseIgnoreRules(s string) []*ignoreRule {
+	var l []*ignoreRule
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// trailing spaces are trimmed unless escaped
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+			line = line[:len(line)-1]
+		}
+
+		r := &ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dir = true
+			line = strings.TrimRight(line, "/")
+		}
+		if len(line) == 0 {
+			continue
+		}
+
+		// pattern without slash matches at any level
+		if !strings.Contains(line, "/") {
+			line = "**/" + line
+		}
+		p, err := newGlobPattern(line)
+		if err != nil {
+			continue
+		}
+		r.p = p
+		l = append(l, r)
+	}
+	return l
+}
+
+// ignoreTable caches rules of ignore files and ignored state of directories
+// per walk, which is nil unless ignore files are honored. Only the last
+// tested directory and its ancestors are cached, so memory does not grow
+// with number of directories walked. ignoreTable is safe for concurrent use.
+type ignoreTable struct {
+	mtx   sync.Mutex
+	files []string // ignore files in each directory in precedence order
+	rules map[string][]*ignoreRule
+	dirs  map[string]bool
+	last  string // the last tested directory
+}
+
+func newIgnoreTable(gitIgnore bool) *ignoreTable {
+	if !gitIgnore {
+		return nil
+	}
+	return &ignoreTable{
+		files: []string{gitIgnoreFile, dirhashIgnoreFile},
+		rules: make(map[string][]*ignoreRule),
+		dirs:  make(map[string]bool),
+	}
+}
+
+// evict drops cache of directories other than d and its ancestors.
+func (t *ignoreTable) evict(d string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if d == t.last {
+		return
+	}
+	t.last = d
+
+	keep := func(x string) bool {
+		return x == d || x == "/" || strings.HasPrefix(d, x+"/")
+	}
+	for x := range t.rules {
+		if !keep(x) {
+			delete(t.rules, x)
+		}
+	}
+	for x := range t.dirs {
+		if !keep(x) {
+			delete(t.dirs, x)
+		}
+	}
+}
+
+// getIgnoreRules returns rules of ignore files in directory d, where
+// later rules take precedence.
+func (h *Hasher) getIgnoreRules(d string) ([]*ignoreRule, error) {
+	t := h.ignores
+	t.mtx.Lock()
+	l, ok := t.rules[d]
+	t.mtx.Unlock()
+	if ok {
+		return l, nil
+	}
+
+	// .git is never tracked
+	if h.opt.GitIgnore && d == h.inputPrefix {
+		l = parseIgnoreRules(".git")
+	}
+	for _, s := range t.files {
+		b, err := h.readIgnoreFile(path.Join(d, s))
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, parseIgnoreRules(string(b))...)
+	}
+
+	t.mtx.Lock()
+	t.rules[d] = l
+	t.mtx.Unlock()
+	return l, nil
+}
+
+// readIgnoreFile returns content of ignore file f, or nil if f is not a
+// regular file.
+func (h *Hasher) readIgnoreFile(f string) ([]byte, error) {
+	info, err := h.fs.lstat(f)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil
+	}
+
+	fp, err := h.fs.open(f)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	return io.ReadAll(fp)
+}
+
+// testIgnoreFile returns true if f is ignored by ignore files in
+// directories from input prefix to f. Files in ignored directories are
+// ignored, same as git.
+func (h *Hasher) testIgnoreFile(f string, isDir bool) (bool, error) {
+	if h.ignores == nil || f == h.inputPrefix || !strings.HasPrefix(f, h.inputPrefix) {
+		return false, nil
+	}
+	h.ignores.evict(path.Dir(f))
+	return h.testIgnoreFileImpl(f, isDir)
+}
+
+func (h *Hasher) testIgnoreFileImpl(f string, isDir bool) (bool, error) {
+	d := path.Dir(f)
+	if d != h.inputPrefix {
+		t := h.ignores
+		t.mtx.Lock()
+		ignored, ok := t.dirs[d]
+		t.mtx.Unlock()
+		if !ok {
+			var err error
+			if ignored, err = h.testIgnoreFileImpl(d, true); err != nil {
+				return false, err
+			}
+			t.mtx.Lock()
+			t.dirs[d] = ignored
+			t.mtx.Unlock()
+		}
+		if ignored {
+			return true, nil
+		}
+	}
+	return h.matchIgnoreRules(f, isDir)
+}
+
+// matchIgnoreRules returns true if f matches rules of the nearest ignore
+// files, where the last matching rule of the deepest directory wins.
+func (h *Hasher) matchIgnoreRules(f string, isDir bool) (bool, error) {
+	for d := path.Dir(f); ; d = path.Dir(d) {
+		l, err := h.getIgnoreRules(d)
+		if err != nil {
+			return false, err
+		}
+		x := strings.TrimPrefix(f[len(d):], "/")
+		for i := len(l) - 1; i >= 0; i-- {
+			r := l[i]
+			if r.dir && !isDir {
+				continue
+			}
+			if r.p.match(x) {
+				return !r.negate, nil
+			}
+		}
+		if d == h.inputPrefix || d == "/" {
+			break
+		}
+	}
+	return false, nil
+}
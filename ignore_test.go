@@ -0,0 +1,196 @@
+package dirhash
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func Test_parseIgnoreRules(t *testing.T) {
+	l := parseIgnoreRules("# comment\n\n*.o\n!keep.o\nbuild/\n/a/b\n\\#x\n\\!y\nz\\ \\ \n[\r\n")
+	ruleList := []struct {
+		p      string
+		negate bool
+		dir    bool
+	}{
+		{"**/*.o", false, false},
+		{"**/keep.o", true, false},
+		{"**/build", false, true},
+		{"/a/b", false, false},
+		{"**/#x", false, false},
+		{"**/!y", false, false},
+		{"**/z\\ \\ ", false, false},
+	}
+	if len(l) != len(ruleList) {
+		t.Fatal(len(l))
+	}
+	for i, x := range ruleList {
+		if r := l[i]; r.p.s != x.p || r.negate != x.negate || r.dir != x.dir {
+			t.Error(x, r.p.s, r.negate, r.dir)
+		}
+	}
+}
+
+func Test_HashInputIgnoreFile(t *testing.T) {
+	d := t.TempDir()
+	fileList := map[string]string{
+		".gitignore":       "*.o\n!keep.o\nbuild/\n/logs/*\n!/logs/x.log\n",
+		"a/.gitignore":     "!*.o\n/b/y\n",
+		"a/b/x.o":          "",
+		"a/b/y":            "",
+		"a/keep.o":         "",
+		"build/z":          "",
+		"src/build/z":      "",
+		"src/x.o":          "",
+		"src/x.c":          "",
+		"logs/x.log":       "",
+		"logs/y.log":       "",
+		"t/.dirhashignore": "*.tmp\n",
+		"t/x.tmp":          "",
+		".git/HEAD":        "",
+	}
+	for f, s := range fileList {
+		x := filepath.Join(d, f)
+		if err := os.MkdirAll(filepath.Dir(x), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(x, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, gitIgnore := range []bool{false, true} {
+		h, err := NewHasher(Options{HashAlgo: SHA256, GitIgnore: gitIgnore})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := h.HashInput(context.Background(), d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var l []string
+		for _, s := range res.Lines {
+			l = append(l, strings.SplitN(s, "  ", 2)[1])
+		}
+		sort.Strings(l)
+
+		// ignore files only apply with gitignore
+		var x []string
+		if gitIgnore {
+			x = []string{".gitignore", "a/.gitignore", "a/b/x.o", "a/keep.o",
+				"logs/x.log", "src/x.c", "t/.dirhashignore"}
+		} else {
+			for f := range fileList {
+				x = append(x, f)
+			}
+			sort.Strings(x)
+		}
+		if !reflect.DeepEqual(l, x) {
+			t.Error(gitIgnore, l)
+		}
+	}
+}
+
+// pruneTestFS fails to open directory bad.
+type pruneTestFS struct {
+	fstest.MapFS
+	bad string
+}
+
+func (x pruneTestFS) Open(name string) (fs.File, error) {
+	if name == x.bad {
+		return nil, fs.ErrPermission
+	}
+	return x.MapFS.Open(name)
+}
+
+func Test_HashInputPruneDirectory(t *testing.T) {
+	fsys := fstest.MapFS{
+		".dirhashignore": {Data: []byte("/c/\n")},
+		"a":              {},
+		".b/x":           {},
+		"c/x":            {},
+		"d/c":            {},
+		"e/x":            {},
+	}
+	optList := []struct {
+		opt Options
+		bad string
+		l   []string
+	}{
+		{Options{IgnoreDotDir: true}, ".b", []string{".b", "c"}},
+		{Options{IgnoreDot: true}, ".b", []string{".b", ".dirhashignore", "c"}},
+		{Options{IgnoreDotDir: true, Squash: true}, "", nil},
+		{Options{Exclude: []string{"e"}}, "e", []string{"c", "e"}},
+		{Options{}, "c", []string{"c"}},
+	}
+	for _, x := range optList {
+		opt := x.opt
+		opt.HashAlgo = SHA256
+		opt.Sort = true
+		opt.Verbose = true
+		opt.GitIgnore = true
+		opt.FS = pruneTestFS{fsys, x.bad}
+		h, err := NewHasher(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := h.HashInput(context.Background(), "/")
+		if err != nil {
+			t.Fatal(x.bad, err)
+		}
+		// squash of older versions walks dot directories
+		if x.l == nil {
+			if squashPruneDotDir {
+				x.l = []string{".b", "c"}
+			} else {
+				x.l = []string{".b/x", "c"}
+			}
+		}
+		if l := res.Stat.Ignored; !reflect.DeepEqual(l, x.l) {
+			t.Error(x.bad, l)
+		}
+	}
+}
+
+func Test_HashInputIgnoreFileEvict(t *testing.T) {
+	fsys := fstest.MapFS{
+		".gitignore":   {Data: []byte("*.o\n")},
+		"a/b/x.o":      {},
+		"a/b/y":        {},
+		"c/d/e/x.o":    {},
+		"c/d/e/y":      {},
+		"f/.gitignore": {Data: []byte("!*.o\n")},
+		"f/x.o":        {},
+	}
+	h, err := NewHasher(Options{HashAlgo: SHA256, Sort: true, GitIgnore: true,
+		FS: fsys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := h.HashInput(context.Background(), "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var l []string
+	for _, s := range res.Lines {
+		l = append(l, strings.SplitN(s, "  ", 2)[1])
+	}
+	if x := []string{".gitignore", "a/b/y", "c/d/e/y", "f/.gitignore", "f/x.o"}; !reflect.DeepEqual(l, x) {
+		t.Error(l)
+	}
+
+	// only the last directory and its ancestors are cached
+	if n := len(h.ignores.rules); n != 2 {
+		t.Error(h.ignores.rules)
+	}
+	if n := len(h.ignores.dirs); n > 1 {
+		t.Error(h.ignores.dirs)
+	}
+}
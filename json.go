@@ -0,0 +1,138 @@
+package dirhash
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	FormatText   = "text"
+	FormatJSON   = "json"   // single object with entries and summary
+	FormatNDJSON = "ndjson" // entry per line followed by summary line
+)
+
+func getAvailableFormat() []string {
+	return []string{
+		FormatText,
+		FormatJSON,
+		FormatNDJSON,
+	}
+}
+
+func isValidFormat(s string) bool {
+	for _, x := range getAvailableFormat() {
+		if s == x {
+			return true
+		}
+	}
+	return false
+}
+
+type jsonEntry struct {
+	Path      string `json:"path,omitempty"`
+	Type      string `json:"type"`
+	Algorithm string `json:"algorithm"`
+	Digest    string `json:"digest"`
+	Written   uint64 `json:"written"`
+	Symlink   string `json:"symlink,omitempty"`
+	Verified  *bool  `json:"verified,omitempty"`
+}
+
+type jsonSummary struct {
+	Algorithm     string `json:"algorithm"`
+	ChunkSize     int64  `json:"chunk_size,omitempty"`
+	Squash        string `json:"squash,omitempty"`
+	SquashVersion int    `json:"squash_version,omitempty"`
+	Verified      *bool  `json:"verified,omitempty"`
+	Files         uint   `json:"files"`
+	Bytes         uint   `json:"bytes"`
+	*Stat
+}
+
+func (h *Hasher) isJSONFormat() bool {
+	return h.opt.Format == FormatJSON || h.opt.Format == FormatNDJSON
+}
+
+func (h *Hasher) printJSONHeader() {
+	h.numJSONEntry = 0
+	if h.opt.Format == FormatJSON {
+		h.writeLine(`{"entries":[`)
+	}
+}
+
+func (h *Hasher) printJSONEntry(e *Entry) error {
+	// squash only prints summary, same as text format
+	if h.opt.Squash {
+		return nil
+	}
+
+	x := &jsonEntry{
+		Type:      getFileTypeString(e.Type),
+		Algorithm: h.getHashAlgoLabel(),
+		Digest:    getHexSum(e.Digest),
+		Written:   e.Written,
+		Symlink:   e.Symlink,
+	}
+	if !h.opt.HashOnly {
+		x.Path = e.Path
+	}
+	if len(h.opt.HashVerify) != 0 {
+		x.Verified = &e.Verified
+	}
+
+	s, err := marshalJSON(x)
+	if err != nil {
+		return err
+	}
+	if h.opt.Format == FormatJSON && h.numJSONEntry > 0 {
+		s = "," + s
+	}
+	h.writeLine(s)
+	h.numJSONEntry++
+
+	return nil
+}
+
+func (h *Hasher) printJSONSummary(sum []byte) error {
+	st := h.getStat()
+	x := &jsonSummary{
+		Algorithm: h.getHashAlgoLabel(),
+		ChunkSize: h.opt.ChunkSize,
+		Files:     st.NumTotal(),
+		Bytes:     st.WrittenTotal(),
+		Stat:      st,
+	}
+	if len(sum) > 0 {
+		x.Squash = getHexSum(sum)
+		x.SquashVersion = squashVersion
+		if len(h.opt.HashVerify) != 0 {
+			verified := h.testHashVerify(sum)
+			x.Verified = &verified
+		}
+	}
+
+	s, err := marshalJSON(x)
+	if err != nil {
+		return err
+	}
+	if h.opt.Format == FormatJSON {
+		h.writeLine(fmt.Sprintf(`],"summary":%s}`, s))
+	} else {
+		h.writeLine(fmt.Sprintf(`{"summary":%s}`, s))
+	}
+
+	return nil
+}
+
+func marshalJSON(v interface{}) (string, error) {
+	// keep <, > and & in paths as is
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
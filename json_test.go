@@ -0,0 +1,114 @@
+package dirhash
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func Test_isValidFormat(t *testing.T) {
+	for _, s := range getAvailableFormat() {
+		if !isValidFormat(s) {
+			t.Error(s)
+		}
+	}
+
+	invalidList := []string{
+		"",
+		"xxx",
+		"JSON",
+	}
+	for _, s := range invalidList {
+		if isValidFormat(s) {
+			t.Error(s)
+		}
+	}
+}
+
+func Test_FormatJSON(t *testing.T) {
+	d := newTestTree(t)
+	for _, format := range []string{FormatJSON, FormatNDJSON} {
+		h, err := NewHasher(Options{HashAlgo: SHA256, Sort: true,
+			FollowSymlink: true, Format: format})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := h.HashInput(context.Background(), d)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var l []jsonEntry
+		var x jsonSummary
+		if format == FormatJSON {
+			var v struct {
+				Entries []jsonEntry  `json:"entries"`
+				Summary *jsonSummary `json:"summary"`
+			}
+			if err := json.Unmarshal([]byte(strings.Join(res.Lines, "\n")), &v); err != nil {
+				t.Fatal(err)
+			}
+			l, x = v.Entries, *v.Summary
+		} else {
+			for _, s := range res.Lines[:len(res.Lines)-1] {
+				var e jsonEntry
+				if err := json.Unmarshal([]byte(s), &e); err != nil {
+					t.Fatal(err)
+				}
+				l = append(l, e)
+			}
+			var v struct {
+				Summary *jsonSummary `json:"summary"`
+			}
+			if err := json.Unmarshal([]byte(res.Lines[len(res.Lines)-1]), &v); err != nil {
+				t.Fatal(err)
+			}
+			x = *v.Summary
+		}
+
+		if len(l) != 4 {
+			t.Fatal(format, l)
+		}
+		if e := l[2]; e.Path != "a/x" || e.Symlink != "l" || e.Type != strReg ||
+			e.Algorithm != SHA256 || e.Written != 3 || e.Verified != nil {
+			t.Error(format, e)
+		}
+		if x.Files != 4 || x.Bytes != 12 || x.NumRegular != 4 || len(x.Squash) != 0 {
+			t.Error(format, x)
+		}
+	}
+}
+
+func Test_FormatJSONVerify(t *testing.T) {
+	d := newTestTree(t)
+	_, b, _ := getStringHash("z", SHA256)
+	h, err := NewHasher(Options{HashAlgo: SHA256, HashVerify: getHexSum(b),
+		Format: FormatNDJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := h.HashInput(context.Background(), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// all entries are printed with verify result
+	n := 0
+	for _, s := range res.Lines[:len(res.Lines)-1] {
+		var e jsonEntry
+		if err := json.Unmarshal([]byte(s), &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Verified == nil {
+			t.Error(e)
+		} else if *e.Verified != (e.Path == "z") {
+			t.Error(e)
+		} else if *e.Verified {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Error(n)
+	}
+}
@@ -0,0 +1,223 @@
+package dirhash
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	progressInterval = time.Second
+	progressWidth    = 79
+)
+
+// progress periodically writes files and bytes hashed so far to
+// Options.Progress. progress is safe for concurrent use, and its methods
+// are no-op if nil.
+type progress struct {
+	w     io.Writer
+	start time.Time
+	total uint64 // bytes to hash, 0 if unknown
+	files uint64 // atomic
+	bytes uint64 // atomic
+
+	mtx    sync.Mutex
+	path   string // file being hashed
+	maxLen int    // length of the longest line written
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// startProgress starts writing progress of walking f if Options.Progress is
+// set, which is stopped by progress.stop.
+func (h *Hasher) startProgress(ctx context.Context, f string) (*progress, error) {
+	if h.opt.Progress == nil {
+		return nil, nil
+	}
+
+	p := &progress{
+		w:    h.opt.Progress,
+		done: make(chan struct{}),
+	}
+	if h.opt.ProgressScan {
+		total, err := h.scanProgressTotal(ctx, f)
+		if err != nil {
+			return nil, err
+		}
+		p.total = total
+	}
+	p.start = time.Now()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		t := time.NewTicker(progressInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				p.print()
+			case <-p.done:
+				return
+			}
+		}
+	}()
+
+	return p, nil
+}
+
+// scanProgressTotal returns bytes of files to be hashed by walking f,
+// where hardlinked inode is counted once.
+func (h *Hasher) scanProgressTotal(ctx context.Context, f string) (uint64, error) {
+	w, err := h.newWalkLimit(f)
+	if err != nil {
+		return 0, err
+	}
+
+	var total uint64
+	links := make(map[fileKey]bool)
+	if err := h.walkDir(f, func(f string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if mount, err := w.isMountPoint(f, d); err != nil {
+			return err
+		} else if mount {
+			return filepath.SkipDir
+		}
+		ok, skip := w.testDepth(f, d)
+		if skip != nil {
+			return skip // a directory
+		}
+		if !ok {
+			return nil
+		}
+		if d.IsDir() {
+			if ignored, err := h.testIgnoreEntry(f, TypeDir); err != nil {
+				return err
+			} else if ignored {
+				return filepath.SkipDir
+			}
+		}
+		x := h.getFileHashTarget(f)
+		if len(x) == 0 {
+			return nil
+		}
+		info, err := h.fs.stat(x)
+		if err != nil {
+			return nil // reported by walk
+		}
+		if st := getFileStat(info); st != nil && st.nlink > 1 {
+			if links[st.getKey()] {
+				return nil
+			}
+			links[st.getKey()] = true
+		}
+		total += uint64(info.Size())
+		return nil
+	}, nil); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// stop stops writing progress, and writes the last one.
+func (p *progress) stop() {
+	if p == nil {
+		return
+	}
+	close(p.done)
+	p.wg.Wait()
+	p.print()
+	fmt.Fprintln(p.w)
+}
+
+func (p *progress) setPath(f string) {
+	if p == nil {
+		return
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.path = f
+}
+
+func (p *progress) addFile() {
+	if p == nil {
+		return
+	}
+	atomic.AddUint64(&p.files, 1)
+}
+
+func (p *progress) addByte(n uint64) {
+	if p == nil {
+		return
+	}
+	atomic.AddUint64(&p.bytes, n)
+}
+
+// print overwrites the current line.
+func (p *progress) print() {
+	files := atomic.LoadUint64(&p.files)
+	bytes := atomic.LoadUint64(&p.bytes)
+	elapsed := time.Since(p.start).Seconds()
+	var rate float64
+	if elapsed > 0 {
+		rate = float64(bytes) / elapsed
+	}
+
+	s := fmt.Sprintf("%s, %s, %s/s", getNumFormatString(uint(files), "file"),
+		getByteSizeString(bytes), getByteSizeString(uint64(rate)))
+	if p.total > 0 {
+		var eta time.Duration
+		if bytes < p.total && rate > 0 {
+			eta = time.Duration(float64(p.total-bytes) / rate * float64(time.Second))
+		}
+		s += fmt.Sprintf(", %d%%, ETA %s", 100*bytes/p.total,
+			eta.Round(time.Second))
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	// keep a single line
+	if len(p.path) > 0 {
+		f := p.path
+		if n := progressWidth - len(s) - 2; len(f) > n {
+			if n > 3 {
+				f = "..." + f[len(f)-n+3:]
+			} else {
+				f = ""
+			}
+		}
+		if len(f) > 0 {
+			s += ", " + f
+		}
+	}
+	if len(s) > p.maxLen {
+		p.maxLen = len(s)
+	}
+	fmt.Fprint(p.w, "\r"+s+strings.Repeat(" ", p.maxLen-len(s)))
+}
+
+func getByteSizeString(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	x, i := float64(n)/unit, 0
+	for x >= unit && i < 4 {
+		x /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f %ciB", x, "KMGTP"[i])
+}
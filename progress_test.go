@@ -0,0 +1,59 @@
+package dirhash
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func Test_getByteSizeString(t *testing.T) {
+	sizeList := []struct {
+		n uint64
+		s string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{1 << 20, "1.0 MiB"},
+		{5 << 30, "5.0 GiB"},
+		{1 << 50, "1.0 PiB"},
+		{1 << 60, "1024.0 PiB"},
+	}
+	for _, x := range sizeList {
+		if s := getByteSizeString(x.n); s != x.s {
+			t.Error(x, s)
+		}
+	}
+}
+
+func Test_HashInputProgress(t *testing.T) {
+	d := newTestTree(t)
+	var w bytes.Buffer
+	h, err := NewHasher(Options{HashAlgo: SHA256, Progress: &w,
+		ProgressScan: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := h.HashInput(context.Background(), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// progress is not part of output
+	for _, s := range res.Lines {
+		if strings.Contains(s, "ETA") {
+			t.Error(res.Lines)
+		}
+	}
+
+	// the last one is written on stop
+	s := strings.TrimRight(w.String(), " \n")
+	if i := strings.LastIndex(s, "\r"); i != -1 {
+		s = s[i+1:]
+	}
+	if !strings.HasPrefix(s, "3 files, 9 B, ") || !strings.Contains(s, ", 100%, ETA 0s") {
+		t.Errorf("%q", s)
+	}
+}
@@ -0,0 +1,145 @@
+package dirhash
+
+import (
+	"context"
+	"sync"
+)
+
+// fileJob is a walked entry whose file is hashed by a worker ahead of
+// walkDirectoryImpl.
+type fileJob struct {
+	f    string // walked path
+	x    string // path to hash, empty if nothing to hash
+	hash *fileHash
+	err  error
+	done chan struct{}
+}
+
+// fileQueue hashes files with Options.Jobs workers, while entries are
+// consumed by walkDirectoryImpl in walk order, so output and squash buffer
+// are the same as serial walk.
+type fileQueue struct {
+	h      *Hasher
+	ctx    context.Context
+	cancel context.CancelFunc
+	work   chan *fileJob
+	jobs   []*fileJob // pending entries in walk order
+	wg     sync.WaitGroup
+}
+
+func (h *Hasher) newFileQueue(ctx context.Context) *fileQueue {
+	assert(h.opt.Jobs > 1)
+	ctx, cancel := context.WithCancel(ctx)
+	q := &fileQueue{
+		h:      h,
+		ctx:    ctx,
+		cancel: cancel,
+		work:   make(chan *fileJob, h.opt.Jobs),
+	}
+	for i := 0; i < h.opt.Jobs; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+	return q
+}
+
+func (q *fileQueue) worker() {
+	defer q.wg.Done()
+	for j := range q.work {
+		j.hash, j.err = q.h.hashFile(q.ctx, j.x)
+		close(j.done)
+	}
+}
+
+// push queues walked entry f, and consumes pending entries while the queue
+// is full.
+func (q *fileQueue) push(f string) error {
+	j := &fileJob{
+		f:    f,
+		x:    q.h.getFileHashTarget(f),
+		done: make(chan struct{}),
+	}
+	if len(j.x) == 0 {
+		close(j.done)
+	} else {
+		q.work <- j
+	}
+	q.jobs = append(q.jobs, j)
+
+	for len(q.jobs) > 2*q.h.opt.Jobs {
+		if err := q.pop(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *fileQueue) pop() error {
+	j := q.jobs[0]
+	q.jobs = q.jobs[1:]
+	<-j.done
+
+	q.h.job = j
+	defer func() { q.h.job = nil }()
+	return q.h.walkDirectoryImpl(q.ctx, j.f)
+}
+
+// flush consumes all pending entries.
+func (q *fileQueue) flush() error {
+	for len(q.jobs) > 0 {
+		if err := q.pop(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close stops workers, pending entries are discarded.
+func (q *fileQueue) close() {
+	q.cancel()
+	close(q.work)
+	q.wg.Wait()
+}
+
+// getFileHashTarget returns path of file which walkDirectoryImpl hashes for
+// walked entry f, or empty string if none.
+func (h *Hasher) getFileHashTarget(f string) string {
+	t, err := getRawFileType(h.fs, f)
+	if err != nil {
+		return ""
+	}
+	if ignored, err := h.testIgnoreEntry(f, t); err != nil || ignored {
+		return ""
+	}
+
+	if t == TypeSymlink {
+		if h.opt.IgnoreSymlink || !h.opt.FollowSymlink {
+			return ""
+		}
+		x, err := canonicalizePath(h.fs, f)
+		if err != nil || len(x) == 0 {
+			return ""
+		}
+		t, err = getFileType(h.fs, x)
+		if err != nil {
+			return ""
+		}
+		f = x
+	}
+
+	if t != TypeReg && t != TypeDevice {
+		return ""
+	}
+	if ignored, err := h.testFilterEntry(f, t); err != nil || ignored {
+		return ""
+	}
+	return f
+}
+
+// getFileHash returns hash of f, which may have been computed by fileQueue.
+func (h *Hasher) getFileHash(ctx context.Context, f string) (*fileHash, error) {
+	if j := h.job; j != nil && j.x == f {
+		return j.hash, j.err
+	}
+	return h.hashFile(ctx, f)
+}
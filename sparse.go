@@ -0,0 +1,96 @@
+package dirhash
+
+import (
+	"io"
+	"io/fs"
+	"os"
+)
+
+// sparseReader reads regular file skipping I/O of holes, which are read as
+// zeros, so digest is the same as plain read.
+type sparseReader struct {
+	fp      *os.File
+	ra      io.ReaderAt // reads data regions of fp
+	off     int64
+	size    int64
+	dataEnd int64 // end of data region at off
+	holeEnd int64 // end of hole region at off
+	plain   bool  // holes are unknown
+	skipped uint64
+}
+
+// newSparseReader returns sparseReader of fp, or nil if fp is not a host
+// file.
+func newSparseReader(fp fs.File, size int64) *sparseReader {
+	x, ok := fp.(*os.File)
+	if !ok || size <= 0 {
+		return nil
+	}
+	return &sparseReader{
+		fp:   x,
+		ra:   x,
+		size: size,
+	}
+}
+
+func (r *sparseReader) Read(p []byte) (int, error) {
+	if r.off >= r.size {
+		return 0, io.EOF
+	}
+	if !r.plain && r.off >= r.dataEnd && r.off >= r.holeEnd {
+		r.seek()
+	}
+
+	// fill zeros if in hole
+	if r.off < r.holeEnd {
+		n := int64(len(p))
+		if n > r.holeEnd-r.off {
+			n = r.holeEnd - r.off
+		}
+		for i := range p[:n] {
+			p[i] = 0
+		}
+		r.off += n
+		r.skipped += uint64(n)
+		return int(n), nil
+	}
+
+	end := r.size
+	if !r.plain && r.dataEnd < end {
+		end = r.dataEnd
+	}
+	n := int64(len(p))
+	if n > end-r.off {
+		n = end - r.off
+	}
+	x, err := r.ra.ReadAt(p[:n], r.off)
+	r.off += int64(x)
+	if err == io.EOF && x > 0 {
+		err = nil
+	}
+	return x, err
+}
+
+// seek finds data or hole region at current offset.
+func (r *sparseReader) seek() {
+	data, err := seekData(r.fp, r.off)
+	if err != nil {
+		r.plain = true
+		return
+	}
+	if data < 0 || data >= r.size {
+		r.holeEnd = r.size // no more data
+		return
+	}
+	if data > r.off {
+		r.holeEnd = data
+		return
+	}
+
+	hole, err := seekHole(r.fp, data)
+	if err != nil {
+		r.plain = true
+		return
+	}
+	r.dataEnd = hole
+}
@@ -0,0 +1,65 @@
+package dirhash
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_sparseReader(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "x")
+	fp, err := os.Create(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+
+	// data at offset 1M and 3M-3 with holes in between
+	size := int64(3 << 20)
+	if err := fp.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fp.WriteAt([]byte("abc"), 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fp.WriteAt([]byte("xyz"), size-3); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, size)
+	copy(b[1<<20:], "abc")
+	copy(b[size-3:], "xyz")
+
+	for _, plain := range []bool{false, true} {
+		r := newSparseReader(fp, size)
+		if r == nil {
+			t.Fatal(f)
+		}
+		r.plain = plain
+		x, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(x, b) {
+			t.Error(plain, len(x))
+		}
+		if plain && r.skipped != 0 {
+			t.Error(plain, r.skipped)
+		}
+		if r.skipped >= uint64(size) {
+			t.Error(plain, r.skipped)
+		}
+	}
+
+	// digest is the same as plain read
+	x, err := getFileHash(context.Background(), hostFS{}, f, SHA256, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, sum, _ := getByteHash(b, SHA256)
+	if x.written != uint64(size) || !bytes.Equal(x.b, sum) {
+		t.Error(x)
+	}
+}
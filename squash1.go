@@ -1,6 +1,6 @@
 //go:build squash1
 
-package main
+package dirhash
 
 import (
 	"sort"
@@ -10,32 +10,44 @@ import (
 var (
 	squashLabel   = "squash"
 	squashVersion = 1
-	squashBuffer  [][]byte
+
+	// symlink is hashed by its base name by default
+	squashSymlinkHash = SymlinkHashName
+
+	// directories ignored by dot options are walked and hashed
+	squashPruneDotDir = false
 )
 
-func initSquashBuffer() {
-	squashBuffer = make([][]byte, 0)
-	for i := range squashBuffer {
-		squashBuffer[i] = make([]byte, 0)
-	}
+type squashBuffer struct {
+	buf [][]byte
+}
+
+func newSquashBuffer() *squashBuffer {
+	s := &squashBuffer{}
+	s.init()
+	return s
+}
+
+func (s *squashBuffer) init() {
+	s.buf = make([][]byte, 0)
 }
 
-func updateSquashBuffer(b []byte) {
+func (s *squashBuffer) update(b []byte) {
 	// get hash to minimize total string size
 	_, tmp, err := getByteHash(b, MD5)
 	if err != nil {
 		panic(err)
 	}
-	squashBuffer = append(squashBuffer, tmp)
+	s.buf = append(s.buf, tmp)
 }
 
-func getSquashBuffer() []byte {
+func (s *squashBuffer) get() []byte {
 	// XXX directly sort [][]byte
-	s := make([]string, 0)
-	for _, b := range squashBuffer {
-		s = append(s, getHexSum(b))
+	l := make([]string, 0)
+	for _, b := range s.buf {
+		l = append(l, getHexSum(b))
 	}
 
-	sort.Strings(s)
-	return []byte(strings.Join(s, ""))
+	sort.Strings(l)
+	return []byte(strings.Join(l, ""))
 }
@@ -1,26 +1,41 @@
-//go:build squash2
+//go:build squash2 || (!squash1 && !squash3)
 
-package main
+package dirhash
 
 var (
 	squashLabel   = "squash"
 	squashVersion = 2
-	squashBuffer  []byte
+
+	// symlink is hashed by its base name by default
+	squashSymlinkHash = SymlinkHashName
+
+	// directories ignored by dot options are walked and hashed
+	squashPruneDotDir = false
 )
 
-func initSquashBuffer() {
-	squashBuffer = make([]byte, 0)
+type squashBuffer struct {
+	buf []byte
+}
+
+func newSquashBuffer() *squashBuffer {
+	s := &squashBuffer{}
+	s.init()
+	return s
+}
+
+func (s *squashBuffer) init() {
+	s.buf = make([]byte, 0)
 }
 
-func updateSquashBuffer(b []byte) {
+func (s *squashBuffer) update(b []byte) {
 	// result depends on append order
-	_, tmp, err := getByteHash(append(squashBuffer, b...), SHA1)
+	_, tmp, err := getByteHash(append(s.buf, b...), SHA1)
 	if err != nil {
 		panic(err)
 	}
-	squashBuffer = tmp
+	s.buf = tmp
 }
 
-func getSquashBuffer() []byte {
-	return squashBuffer
+func (s *squashBuffer) get() []byte {
+	return s.buf
 }
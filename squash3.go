@@ -0,0 +1,41 @@
+//go:build squash3
+
+package dirhash
+
+var (
+	squashLabel   = "squash"
+	squashVersion = 3
+
+	// symlink is hashed by its target by default
+	squashSymlinkHash = SymlinkHashTarget
+
+	// directories ignored by dot options are pruned
+	squashPruneDotDir = true
+)
+
+type squashBuffer struct {
+	buf []byte
+}
+
+func newSquashBuffer() *squashBuffer {
+	s := &squashBuffer{}
+	s.init()
+	return s
+}
+
+func (s *squashBuffer) init() {
+	s.buf = make([]byte, 0)
+}
+
+func (s *squashBuffer) update(b []byte) {
+	// result depends on append order
+	_, tmp, err := getByteHash(append(s.buf, b...), SHA1)
+	if err != nil {
+		panic(err)
+	}
+	s.buf = tmp
+}
+
+func (s *squashBuffer) get() []byte {
+	return s.buf
+}
@@ -1,14 +1,14 @@
-package main
+package dirhash
 
 import (
 	"strings"
 	"testing"
 )
 
-func Test_initSquashBuffer(t *testing.T) {
-	initSquashBuffer()
+func Test_newSquashBuffer(t *testing.T) {
+	s := newSquashBuffer()
 
-	if b := getSquashBuffer(); b == nil {
+	if b := s.get(); b == nil {
 		t.Error(b)
 	} else if len(b) != 0 {
 		t.Error(b)
@@ -16,31 +16,31 @@ func Test_initSquashBuffer(t *testing.T) {
 }
 
 func Test_updateSquashBuffer(t *testing.T) {
-	initSquashBuffer()
+	s := newSquashBuffer()
 
-	updateSquashBuffer([]byte(""))
-	if b := getSquashBuffer(); b == nil {
+	s.update([]byte(""))
+	if b := s.get(); b == nil {
 		t.Error(b)
 	} else if len(b) == 0 {
 		t.Error(b)
 	}
 
-	updateSquashBuffer([]byte(""))
-	if b := getSquashBuffer(); b == nil {
+	s.update([]byte(""))
+	if b := s.get(); b == nil {
 		t.Error(b)
 	} else if len(b) == 0 {
 		t.Error(b)
 	}
 
-	updateSquashBuffer([]byte("xxx"))
-	if b := getSquashBuffer(); b == nil {
+	s.update([]byte("xxx"))
+	if b := s.get(); b == nil {
 		t.Error(b)
 	} else if len(b) == 0 {
 		t.Error(b)
 	}
 
-	updateSquashBuffer([]byte(strings.Repeat("x", 123456)))
-	if b := getSquashBuffer(); b == nil {
+	s.update([]byte(strings.Repeat("x", 123456)))
+	if b := s.get(); b == nil {
 		t.Error(b)
 	} else if len(b) == 0 {
 		t.Error(b)
@@ -1,196 +1,396 @@
-package main
+package dirhash
 
 import (
-	"fmt"
+	"sync"
 )
 
-var (
-	statDirectory   []string // hashed
-	statRegular     []string // hashed
-	statDevice      []string // hashed
-	statSymlink     []string // hashed
-	statUnsupported []string
-	statInvalid     []string
-	statIgnored     []string
+// stat is safe for concurrent use.
+// Paths of hashed files are only counted, and paths of ignored files and
+// hardlinks are only kept if printed, so memory does not grow with number
+// of files walked.
+type stat struct {
+	mtx sync.Mutex
+
+	statDirectory     uint // hashed
+	statRegular       uint // hashed
+	statDevice        uint // hashed
+	statSymlink       uint // hashed
+	statUnsupported   []string
+	statInvalid       []string
+	statIgnored       []string
+	statCacheMismatch []string
+	statMountPoint    []string // skipped
+	statSymlinkLoop   []string // symlinks to ancestor directories
+	statHardlink      []string // links other than the first one
+	statHardlinkTo    []string // the first link of each statHardlink
+	hardlinkFirst     map[fileKey]string
+	keepIgnored       bool // keep statIgnored
+	keepHardlink      bool // keep statHardlink and statHardlinkTo
 
 	writtenDirectory uint // hashed
 	writtenRegular   uint // hashed
 	writtenDevice    uint // hashed
 	writtenSymlink   uint // hashed
-)
+	holeSkipped      uint // read as zeros without I/O
+}
+
+func newStat() *stat {
+	s := &stat{}
+	s.initStat()
+	return s
+}
 
-func initStat() {
-	statDirectory = make([]string, 0)
-	statRegular = make([]string, 0)
-	statDevice = make([]string, 0)
-	statSymlink = make([]string, 0)
-	statUnsupported = make([]string, 0)
-	statInvalid = make([]string, 0)
-	statIgnored = make([]string, 0)
+func (s *stat) initStat() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
 
-	writtenDirectory = 0
-	writtenRegular = 0
-	writtenDevice = 0
-	writtenSymlink = 0
+	s.statDirectory = 0
+	s.statRegular = 0
+	s.statDevice = 0
+	s.statSymlink = 0
+	s.statUnsupported = make([]string, 0)
+	s.statInvalid = make([]string, 0)
+	s.statIgnored = make([]string, 0)
+	s.statCacheMismatch = make([]string, 0)
+	s.statMountPoint = make([]string, 0)
+	s.statSymlinkLoop = make([]string, 0)
+	s.statHardlink = make([]string, 0)
+	s.statHardlinkTo = make([]string, 0)
+	s.hardlinkFirst = make(map[fileKey]string)
+
+	s.writtenDirectory = 0
+	s.writtenRegular = 0
+	s.writtenDevice = 0
+	s.writtenSymlink = 0
+	s.holeSkipped = 0
 }
 
 // num stat
-func numStatTotal() uint {
-	return numStatDirectory() + numStatRegular() + numStatDevice() + numStatSymlink()
+func (s *stat) numStatTotal() uint {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.statDirectory + s.statRegular + s.statDevice + s.statSymlink
 }
 
-func numStatDirectory() uint {
-	return uint(len(statDirectory))
+func (s *stat) numStatDirectory() uint {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.statDirectory
 }
 
-func numStatRegular() uint {
-	return uint(len(statRegular))
+func (s *stat) numStatRegular() uint {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.statRegular
 }
 
-func numStatDevice() uint {
-	return uint(len(statDevice))
+func (s *stat) numStatDevice() uint {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.statDevice
 }
 
-func numStatSymlink() uint {
-	return uint(len(statSymlink))
+func (s *stat) numStatSymlink() uint {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.statSymlink
 }
 
 /*
-func numStatUnsupported() uint {
-	return uint(len(statUnsupported))
+func (s *stat) numStatUnsupported() uint {
+	return uint(len(s.statUnsupported))
 }
 
-func numStatInvalid() uint {
-	return uint(len(statInvalid))
+func (s *stat) numStatInvalid() uint {
+	return uint(len(s.statInvalid))
 }
 
-func numStatIgnored() uint {
-	return uint(len(statIgnored))
+func (s *stat) numStatIgnored() uint {
+	return uint(len(s.statIgnored))
 }
 */
 
 // append stat
-func appendStatTotal() {
+func (s *stat) appendStatTotal() {
+}
+
+func (s *stat) appendStatDirectory() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.statDirectory++
 }
 
-func appendStatDirectory(f string) {
-	statDirectory = append(statDirectory, f)
+func (s *stat) appendStatRegular() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.statRegular++
 }
 
-func appendStatRegular(f string) {
-	statRegular = append(statRegular, f)
+func (s *stat) appendStatDevice() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.statDevice++
 }
 
-func appendStatDevice(f string) {
-	statDevice = append(statDevice, f)
+func (s *stat) appendStatSymlink() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.statSymlink++
 }
 
-func appendStatSymlink(f string) {
-	statSymlink = append(statSymlink, f)
+func (s *stat) appendStatUnsupported(f string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.statUnsupported = append(s.statUnsupported, f)
 }
 
-func appendStatUnsupported(f string) {
-	statUnsupported = append(statUnsupported, f)
+func (s *stat) appendStatInvalid(f string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.statInvalid = append(s.statInvalid, f)
 }
 
-func appendStatInvalid(f string) {
-	statInvalid = append(statInvalid, f)
+func (s *stat) appendStatIgnored(f string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.keepIgnored {
+		s.statIgnored = append(s.statIgnored, f)
+	}
+}
+
+func (s *stat) appendStatCacheMismatch(f string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.statCacheMismatch = append(s.statCacheMismatch, f)
 }
 
-func appendStatIgnored(f string) {
-	statIgnored = append(statIgnored, f)
+func (s *stat) appendStatMountPoint(f string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.statMountPoint = append(s.statMountPoint, f)
+}
+
+func (s *stat) appendStatSymlinkLoop(f string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.statSymlinkLoop = append(s.statSymlinkLoop, f)
+}
+
+// appendStatHardlink returns the first link of hardlinked file f, or empty
+// string if f is the first one.
+func (s *stat) appendStatHardlink(f string, k fileKey) string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	x, ok := s.hardlinkFirst[k]
+	if !ok {
+		s.hardlinkFirst[k] = f
+		return ""
+	} else if x == f {
+		return "" // e.g. symlink target
+	}
+	if s.keepHardlink {
+		s.statHardlink = append(s.statHardlink, f)
+		s.statHardlinkTo = append(s.statHardlinkTo, x)
+	}
+	return x
 }
 
 // print stat
-/*
-func printStatDirectory() {
-	printStat(statDirectory, strDir)
+func (h *Hasher) printStatUnsupported() {
+	h.printStat(h.stat.statUnsupported, strUnsupported)
 }
 
-func printStatRegular() {
-	printStat(statRegular, strReg)
+func (h *Hasher) printStatInvalid() {
+	h.printStat(h.stat.statInvalid, strInvalid)
 }
 
-func printStatDevice() {
-	printStat(statDevice, strDevice)
+func (h *Hasher) printStatIgnored() {
+	h.printStat(h.stat.statIgnored, "ignored file")
 }
 
-func printStatSymlink() {
-	printStat(statSymlink, strSymlink)
+func (h *Hasher) printStatCacheMismatch() {
+	h.printStat(h.stat.statCacheMismatch, "cache mismatch file")
 }
-*/
 
-func printStatUnsupported() {
-	printStat(statUnsupported, strUnsupported)
+func (h *Hasher) printStatMountPoint() {
+	h.printStat(h.stat.statMountPoint, "skipped mount point")
 }
 
-func printStatInvalid() {
-	printStat(statInvalid, strInvalid)
+func (h *Hasher) printStatSymlinkLoop() {
+	h.printStat(h.stat.statSymlinkLoop, "symlink loop")
 }
 
-func printStatIgnored() {
-	printStat(statIgnored, "ignored file")
+func (h *Hasher) printStatHardlink() {
+	l := h.stat.statHardlink
+	if len(l) == 0 {
+		return
+	}
+	h.printNumFormatString(uint(len(l)), "hardlinked file")
+
+	for i, f := range l {
+		h.printf("%s (hardlink to %s)",
+			h.getRealPath(f), h.getRealPath(h.stat.statHardlinkTo[i]))
+	}
 }
 
-func printStat(l []string, msg string) {
+func (h *Hasher) printStat(l []string, msg string) {
 	if len(l) == 0 {
 		return
 	}
-	printNumFormatString(uint(len(l)), msg)
+	h.printNumFormatString(uint(len(l)), msg)
 
 	for _, v := range l {
-		f := getRealPath(v)
-		t1, _ := getRawFileType(v)
-		t2, _ := getFileType(v)
-		assert(t2 != typeSymlink) // symlink chains resolved
-		if t1 == typeSymlink {
-			assert(optIgnoreSymlink || t2 == typeDir || t2 == typeInvalid)
-			fmt.Printf("%s (%s -> %s)\n",
+		f := h.getRealPath(v)
+		t1, _ := getRawFileType(h.fs, v)
+		t2, _ := getFileType(h.fs, v)
+		assert(t2 != TypeSymlink) // symlink chains resolved
+		if t1 == TypeSymlink {
+			// ignored symlinks may point to any type
+			h.printf("%s (%s -> %s)",
 				f, getFileTypeString(t1), getFileTypeString(t2))
 		} else {
-			assert(t2 != typeDir)
-			fmt.Printf("%s (%s)\n", f, getFileTypeString(t1))
+			// ignored directories are printed without their entries
+			h.printf("%s (%s)", f, getFileTypeString(t1))
 		}
 	}
 }
 
 // num written
-func numWrittenTotal() uint {
-	return numWrittenDirectory() + numWrittenRegular() + numWrittenDevice() + numWrittenSymlink()
+func (s *stat) numWrittenTotal() uint {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.writtenDirectory + s.writtenRegular + s.writtenDevice + s.writtenSymlink
 }
 
-func numWrittenDirectory() uint {
-	return writtenDirectory
+func (s *stat) numWrittenDirectory() uint {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.writtenDirectory
 }
 
-func numWrittenRegular() uint {
-	return writtenRegular
+func (s *stat) numWrittenRegular() uint {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.writtenRegular
 }
 
-func numWrittenDevice() uint {
-	return writtenDevice
+func (s *stat) numWrittenDevice() uint {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.writtenDevice
 }
 
-func numWrittenSymlink() uint {
-	return writtenSymlink
+func (s *stat) numWrittenSymlink() uint {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.writtenSymlink
 }
 
 // append written
-func appendWrittenTotal(written uint64) {
+func (s *stat) appendWrittenTotal(written uint64) {
+}
+
+func (s *stat) appendWrittenDirectory(written uint64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.writtenDirectory += uint(written)
+}
+
+func (s *stat) appendWrittenRegular(written uint64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.writtenRegular += uint(written)
+}
+
+func (s *stat) appendWrittenDevice(written uint64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.writtenDevice += uint(written)
+}
+
+func (s *stat) appendWrittenSymlink(written uint64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.writtenSymlink += uint(written)
+}
+
+func (s *stat) numHoleSkipped() uint {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.holeSkipped
+}
+
+func (s *stat) appendHoleSkipped(skipped uint64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.holeSkipped += uint(skipped)
+}
+
+// Stat is a snapshot of stats of a walk.
+type Stat struct {
+	NumDirectory     uint     `json:"directory"`
+	NumRegular       uint     `json:"regular"`
+	NumDevice        uint     `json:"device"`
+	NumSymlink       uint     `json:"symlink"`
+	WrittenDirectory uint     `json:"directory_written"`
+	WrittenRegular   uint     `json:"regular_written"`
+	WrittenDevice    uint     `json:"device_written"`
+	WrittenSymlink   uint     `json:"symlink_written"`
+	HoleSkipped      uint     `json:"hole_skipped"`
+	Unsupported      []string `json:"unsupported"`
+	Invalid          []string `json:"invalid"`
+	Ignored          []string `json:"ignored"` // only if Options.Verbose or json format
+	CacheMismatch    []string `json:"cache_mismatch"`
+	MountPoint       []string `json:"mount_point"`  // skipped by Options.Xdev
+	SymlinkLoop      []string `json:"symlink_loop"` // not walked by Options.FollowDirSymlink
+
+	// Hardlink maps hardlinks to the first link in walk order.
+	// Hardlink is only collected if Options.ReportHardlink or json format.
+	Hardlink map[string]string `json:"hardlink"`
+}
+
+func (h *Hasher) getStat() *Stat {
+	return &Stat{
+		NumDirectory:     h.stat.numStatDirectory(),
+		NumRegular:       h.stat.numStatRegular(),
+		NumDevice:        h.stat.numStatDevice(),
+		NumSymlink:       h.stat.numStatSymlink(),
+		WrittenDirectory: h.stat.numWrittenDirectory(),
+		WrittenRegular:   h.stat.numWrittenRegular(),
+		WrittenDevice:    h.stat.numWrittenDevice(),
+		WrittenSymlink:   h.stat.numWrittenSymlink(),
+		HoleSkipped:      h.stat.numHoleSkipped(),
+		Unsupported:      h.getRealPathList(h.stat.statUnsupported),
+		Invalid:          h.getRealPathList(h.stat.statInvalid),
+		Ignored:          h.getRealPathList(h.stat.statIgnored),
+		CacheMismatch:    h.getRealPathList(h.stat.statCacheMismatch),
+		MountPoint:       h.getRealPathList(h.stat.statMountPoint),
+		SymlinkLoop:      h.getRealPathList(h.stat.statSymlinkLoop),
+		Hardlink:         h.getHardlinkMap(),
+	}
 }
 
-func appendWrittenDirectory(written uint64) {
-	writtenDirectory += uint(written)
+func (h *Hasher) getHardlinkMap() map[string]string {
+	m := make(map[string]string)
+	for i, f := range h.stat.statHardlink {
+		m[h.getRealPath(f)] = h.getRealPath(h.stat.statHardlinkTo[i])
+	}
+	return m
 }
 
-func appendWrittenRegular(written uint64) {
-	writtenRegular += uint(written)
+func (h *Hasher) getRealPathList(l []string) []string {
+	ret := make([]string, 0, len(l))
+	for _, f := range l {
+		ret = append(ret, h.getRealPath(f))
+	}
+	return ret
 }
 
-func appendWrittenDevice(written uint64) {
-	writtenDevice += uint(written)
+func (s *Stat) NumTotal() uint {
+	return s.NumDirectory + s.NumRegular + s.NumDevice + s.NumSymlink
 }
 
-func appendWrittenSymlink(written uint64) {
-	writtenSymlink += uint(written)
+func (s *Stat) WrittenTotal() uint {
+	return s.WrittenDirectory + s.WrittenRegular + s.WrittenDevice + s.WrittenSymlink
 }
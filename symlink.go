@@ -0,0 +1,69 @@
+package dirhash
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	SymlinkHashName      = "name"       // symlink base name
+	SymlinkHashTarget    = "target"     // symlink target as is
+	SymlinkHashTargetRel = "target_rel" // symlink target relative to input prefix
+)
+
+func getAvailableSymlinkHash() []string {
+	return []string{
+		SymlinkHashName,
+		SymlinkHashTarget,
+		SymlinkHashTargetRel,
+	}
+}
+
+func isValidSymlinkHash(s string) bool {
+	for _, x := range getAvailableSymlinkHash() {
+		if s == x {
+			return true
+		}
+	}
+	return false
+}
+
+// getSymlinkHashString returns string of symlink f to hash in
+// Options.SymlinkHash.
+func (h *Hasher) getSymlinkHashString(f string) (string, error) {
+	if h.opt.SymlinkHash == SymlinkHashName {
+		return path.Base(f), nil
+	}
+
+	s, err := h.fs.readLink(f)
+	if err != nil {
+		return "", err
+	}
+	if h.opt.SymlinkHash == SymlinkHashTarget {
+		return s, nil
+	}
+	return h.getSymlinkTargetRel(f, s), nil
+}
+
+// getSymlinkTargetRel returns target s of symlink f lexically normalized
+// relative to input prefix, so links to the same file within input are
+// the same regardless of input location. Absolute target out of input
+// prefix is kept absolute.
+func (h *Hasher) getSymlinkTargetRel(f string, s string) string {
+	x := s
+	if !path.IsAbs(x) {
+		x = path.Join(path.Dir(f), x)
+	}
+	x = path.Clean(x)
+
+	prefix := h.inputPrefix
+	if path.IsAbs(s) && x != prefix && !strings.HasPrefix(x, strings.TrimSuffix(prefix, "/")+"/") {
+		return x
+	}
+	r, err := filepath.Rel(prefix, x)
+	if err != nil {
+		return x
+	}
+	return r
+}
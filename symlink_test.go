@@ -0,0 +1,113 @@
+package dirhash
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_isValidSymlinkHash(t *testing.T) {
+	for _, s := range getAvailableSymlinkHash() {
+		if !isValidSymlinkHash(s) {
+			t.Error(s)
+		}
+	}
+	for _, s := range []string{"", "xxx", "Name"} {
+		if isValidSymlinkHash(s) {
+			t.Error(s)
+		}
+	}
+
+	h, err := NewHasher(Options{HashAlgo: SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.opt.SymlinkHash != squashSymlinkHash {
+		t.Error(h.opt.SymlinkHash)
+	}
+	if _, err := NewHasher(Options{HashAlgo: SHA256, SymlinkHash: "xxx"}); err == nil {
+		t.Error("xxx")
+	}
+}
+
+func Test_getSymlinkTargetRel(t *testing.T) {
+	targetList := []struct {
+		prefix string
+		f      string
+		s      string
+		x      string
+	}{
+		{"/a", "/a/l", "x", "x"},
+		{"/a", "/a/b/l", "x", "b/x"},
+		{"/a", "/a/b/l", "../x", "x"},
+		{"/a", "/a/b/l", "./c/../x", "b/x"},
+		{"/a", "/a/b/l", "/a/x", "x"},
+		{"/a", "/a/b/l", "/a", "."},
+		{"/a", "/a/b/l", "/ab/x", "/ab/x"},
+		{"/a", "/a/b/l", "../../x", "../x"},
+		{"/", "/b/l", "/x", "x"},
+	}
+	for _, x := range targetList {
+		h := &Hasher{inputPrefix: x.prefix}
+		if s := h.getSymlinkTargetRel(x.f, x.s); s != x.x {
+			t.Error(x, s)
+		}
+	}
+}
+
+func Test_HashInputSymlinkHash(t *testing.T) {
+	d := newTestTree(t)
+	l := filepath.Join(d, "l")
+	getDigest := func(mode string) string {
+		h, err := NewHasher(Options{HashAlgo: SHA256, SymlinkHash: mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var x string
+		if _, err := h.WalkInput(context.Background(), d, func(e *Entry) error {
+			if e.Path == "l" {
+				x = getHexSum(e.Digest)
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return x
+	}
+
+	for _, x := range []struct {
+		mode string
+		s    string
+	}{
+		{SymlinkHashName, "l"},
+		{SymlinkHashTarget, "a/x"},
+		{SymlinkHashTargetRel, "a/x"},
+	} {
+		_, b, _ := getStringHash(x.s, SHA256)
+		if s := getDigest(x.mode); s != getHexSum(b) {
+			t.Error(x, s)
+		}
+	}
+
+	// retargeted symlink is detected unless name
+	if err := os.Remove(l); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(d, "z"), l); err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range []struct {
+		mode string
+		s    string
+	}{
+		{SymlinkHashName, "l"},
+		{SymlinkHashTarget, filepath.Join(d, "z")},
+		{SymlinkHashTargetRel, "z"},
+	} {
+		_, b, _ := getStringHash(x.s, SHA256)
+		if s := getDigest(x.mode); s != getHexSum(b) {
+			t.Error(x, s)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+//go:build linux
+
+package dirhash
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	seekWhenceData = 3 // SEEK_DATA
+	seekWhenceHole = 4 // SEEK_HOLE
+)
+
+// seekData returns offset of the next data region at or after off, or -1
+// if none.
+func seekData(fp *os.File, off int64) (int64, error) {
+	x, err := fp.Seek(off, seekWhenceData)
+	if errors.Is(err, syscall.ENXIO) {
+		return -1, nil
+	}
+	return x, err
+}
+
+// seekHole returns offset of the next hole at or after off, which is file
+// size if none.
+func seekHole(fp *os.File, off int64) (int64, error) {
+	return fp.Seek(off, seekWhenceHole)
+}
+
+// openFile opens f without updating its atime, which is only permitted to
+// file owner or privileged user.
+func openFile(f string) (*os.File, error) {
+	fp, err := os.OpenFile(f, os.O_RDONLY|syscall.O_NOATIME, 0)
+	if errors.Is(err, syscall.EPERM) {
+		return os.Open(f)
+	}
+	return fp, err
+}
+
+// dropFileCache advises kernel to drop page cache of fp read, so hashing
+// does not evict page cache of other workloads.
+func dropFileCache(fp fs.File) {
+	if x, ok := fp.(*os.File); ok {
+		_ = unix.Fadvise(int(x.Fd()), 0, 0, unix.FADV_DONTNEED)
+	}
+}
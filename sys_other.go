@@ -0,0 +1,26 @@
+//go:build !linux
+
+package dirhash
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+var errSeekUnsupported = errors.New("seek data or hole unsupported")
+
+func seekData(fp *os.File, off int64) (int64, error) {
+	return 0, errSeekUnsupported
+}
+
+func seekHole(fp *os.File, off int64) (int64, error) {
+	return 0, errSeekUnsupported
+}
+
+func openFile(f string) (*os.File, error) {
+	return os.Open(f)
+}
+
+func dropFileCache(fp fs.File) {
+}
@@ -0,0 +1,24 @@
+//go:build linux || openbsd || dragonfly || solaris
+
+package dirhash
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// getFileStat returns metadata of info, or nil if unavailable.
+func getFileStat(info fs.FileInfo) *fileStat {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	return &fileStat{
+		dev:   uint64(st.Dev),
+		ino:   uint64(st.Ino),
+		size:  st.Size,
+		mtime: st.Mtim.Nano(),
+		ctime: st.Ctim.Nano(),
+		nlink: uint64(st.Nlink),
+	}
+}
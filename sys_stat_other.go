@@ -0,0 +1,12 @@
+//go:build !linux && !openbsd && !dragonfly && !solaris && !darwin && !freebsd && !netbsd
+
+package dirhash
+
+import (
+	"io/fs"
+)
+
+// getFileStat returns metadata of info, or nil if unavailable.
+func getFileStat(info fs.FileInfo) *fileStat {
+	return nil
+}
@@ -0,0 +1,98 @@
+package dirhash
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+const rateBurst = 100 * time.Millisecond
+
+// rateLimiter limits bytes read per second across files and workers.
+// nil rateLimiter is unlimited.
+type rateLimiter struct {
+	mtx  sync.Mutex
+	rate int64 // bytes per second
+	next time.Time
+}
+
+func newRateLimiter(rate int64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{rate: rate}
+}
+
+// wait blocks until n bytes read are within the rate.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+
+	// keep credit of oversleep up to rateBurst
+	l.mtx.Lock()
+	now := time.Now()
+	if x := now.Add(-rateBurst); l.next.Before(x) {
+		l.next = x
+	}
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.rate))
+	d := l.next.Sub(now)
+	l.mtx.Unlock()
+
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+type throttledReader struct {
+	ctx context.Context
+	r   io.Reader
+	l   *rateLimiter
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err := r.l.wait(r.ctx, n); err != nil {
+		return n, err
+	}
+	return n, err
+}
+
+type throttledReaderAt struct {
+	ctx context.Context
+	ra  io.ReaderAt
+	l   *rateLimiter
+}
+
+func (r *throttledReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.ra.ReadAt(p, off)
+	if err := r.l.wait(r.ctx, n); err != nil {
+		return n, err
+	}
+	return n, err
+}
+
+// throttleReader returns r limited by l, or r as is if l is nil.
+func throttleReader(ctx context.Context, r io.Reader, l *rateLimiter) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &throttledReader{ctx, r, l}
+}
+
+// throttleReaderAt returns ra limited by l, or ra as is if l is nil.
+func throttleReaderAt(ctx context.Context, ra io.ReaderAt, l *rateLimiter) io.ReaderAt {
+	if l == nil {
+		return ra
+	}
+	return &throttledReaderAt{ctx, ra, l}
+}
@@ -0,0 +1,75 @@
+package dirhash
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func Test_rateLimiter(t *testing.T) {
+	if l := newRateLimiter(0); l != nil {
+		t.Error(l)
+	}
+	var l *rateLimiter
+	if err := l.wait(context.Background(), 1<<30); err != nil {
+		t.Error(err)
+	}
+
+	// 300 KB at 1 MB/s with 100ms burst
+	l = newRateLimiter(1000000)
+	r := throttleReader(context.Background(), bytes.NewReader(make([]byte, 300000)), l)
+	t0 := time.Now()
+	n, err := io.Copy(io.Discard, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 300000 {
+		t.Error(n)
+	}
+	if d := time.Since(t0); d < 150*time.Millisecond {
+		t.Error(d)
+	}
+
+	// wait is interrupted by ctx
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.wait(ctx, 1000000); err != context.Canceled {
+		t.Error(err)
+	}
+}
+
+func Test_HashInputBwLimit(t *testing.T) {
+	d := newTestTree(t)
+	var l [][]string
+	for _, opt := range []Options{
+		{HashAlgo: SHA256, Sort: true},
+		{HashAlgo: SHA256, Sort: true, BwLimit: 1000},
+		{HashAlgo: SHA256, Sort: true, BwLimit: 1000, ChunkSize: 2},
+	} {
+		h, err := NewHasher(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := h.HashInput(context.Background(), d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l = append(l, res.Lines)
+	}
+	for i := 0; i < 2; i++ {
+		if len(l[i]) == 0 || len(l[i]) != len(l[i+1]) {
+			t.Fatal(l)
+		}
+	}
+	for i := range l[0] {
+		if l[0][i] != l[1][i] {
+			t.Error(l[0][i], l[1][i])
+		}
+	}
+
+	if _, err := NewHasher(Options{HashAlgo: SHA256, BwLimit: -1}); err == nil {
+		t.Error("negative bwlimit")
+	}
+}
@@ -1,23 +1,22 @@
-package main
+package dirhash
 
 import (
 	"fmt"
 	"io/fs"
 	"os"
-	"path/filepath"
 	"runtime"
 	"strings"
 )
 
-type fileType int
+type FileType int
 
 const (
-	typeDir fileType = iota
-	typeReg
-	typeDevice
-	typeSymlink
-	typeUnsupported
-	typeInvalid
+	TypeDir FileType = iota
+	TypeReg
+	TypeDevice
+	TypeSymlink
+	TypeUnsupported
+	TypeInvalid
 
 	strDir         = "directory"
 	strReg         = "regular file"
@@ -27,9 +26,9 @@ const (
 	strInvalid     = "invalid file"
 )
 
-func canonicalizePath(l string) (string, error) {
-	if s, err := filepath.EvalSymlinks(l); err != nil {
-		if info, err := os.Lstat(l); err != nil {
+func canonicalizePath(fsys fileSystem, l string) (string, error) {
+	if s, err := fsys.evalSymlinks(l); err != nil {
+		if info, err := fsys.lstat(l); err != nil {
 			return "", err
 		} else if info.Mode()&fs.ModeSymlink != 0 {
 			return "", nil // ignore broken symlink
@@ -49,35 +48,35 @@ func getPathSeparator() rune {
 	return os.PathSeparator
 }
 
-func getRawFileType(f string) (fileType, error) {
-	if info, err := os.Lstat(f); err != nil {
-		return typeInvalid, err
+func getRawFileType(fsys fileSystem, f string) (FileType, error) {
+	if info, err := fsys.lstat(f); err != nil {
+		return TypeInvalid, err
 	} else {
 		return getModeType(info.Mode())
 	}
 }
 
-func getFileType(f string) (fileType, error) {
-	if info, err := os.Stat(f); err != nil {
-		return typeInvalid, err
+func getFileType(fsys fileSystem, f string) (FileType, error) {
+	if info, err := fsys.stat(f); err != nil {
+		return TypeInvalid, err
 	} else {
 		return getModeType(info.Mode())
 	}
 }
 
-func getFileTypeString(t fileType) string {
+func getFileTypeString(t FileType) string {
 	switch t {
-	case typeDir:
+	case TypeDir:
 		return strDir
-	case typeReg:
+	case TypeReg:
 		return strReg
-	case typeDevice:
+	case TypeDevice:
 		return strDevice
-	case typeSymlink:
+	case TypeSymlink:
 		return strSymlink
-	case typeUnsupported:
+	case TypeUnsupported:
 		return strUnsupported
-	case typeInvalid:
+	case TypeInvalid:
 		return strInvalid
 	default:
 		panicFileType("", "unknown", t)
@@ -85,30 +84,34 @@ func getFileTypeString(t fileType) string {
 	}
 }
 
-func getModeType(m fs.FileMode) (fileType, error) {
+func (t FileType) String() string {
+	return getFileTypeString(t)
+}
+
+func getModeType(m fs.FileMode) (FileType, error) {
 	if m.IsDir() {
-		return typeDir, nil
+		return TypeDir, nil
 	} else if m.IsRegular() {
-		return typeReg, nil
+		return TypeReg, nil
 	} else if m&fs.ModeDevice != 0 {
 		// XXX assuming blk on Linux, chr on *BSD
-		return typeDevice, nil
+		return TypeDevice, nil
 	} else if m&fs.ModeSymlink != 0 {
-		return typeSymlink, nil
+		return TypeSymlink, nil
 	} else {
-		return typeUnsupported, nil
+		return TypeUnsupported, nil
 	}
 }
 
-func pathExists(f string) (bool, error) {
-	if _, err := os.Stat(f); err == nil {
+func pathExists(fsys fileSystem, f string) (bool, error) {
+	if _, err := fsys.stat(f); err == nil {
 		return true, nil
 	} else {
 		return false, err
 	}
 }
 
-func isValidHexSum(s string) (string, bool) {
+func IsValidHexSum(s string) (string, bool) {
 	orig := s
 	s = strings.TrimPrefix(s, "0x")
 
@@ -124,12 +127,59 @@ func isValidHexSum(s string) (string, bool) {
 	return s, true
 }
 
-func getXsumFormatString(f string, h string) string {
-	if optSwap {
-		h, f = f, h
+func (h *Hasher) getXsumFormatString(f string, s string) string {
+	// escape file path same as coreutils, unless NUL terminated
+	prefix := ""
+	if x := EscapePath(f); x != f && !h.opt.Zero {
+		prefix = "\\"
+		f = x
+	}
+
+	// compatible with shaXsum --tag and BSD commands
+	if h.opt.Tag {
+		return fmt.Sprintf("%s%s (%s) = %s", prefix, getHashAlgoTag(h.getHashAlgoLabel()), f, s)
+	}
+
+	if h.opt.Swap {
+		s, f = f, s
 	}
 	// compatible with shaXsum commands
-	return fmt.Sprintf("%s  %s", h, f)
+	return fmt.Sprintf("%s%s  %s", prefix, s, f)
+}
+
+// EscapePath escapes \, \n and \r in f same as coreutils.
+func EscapePath(f string) string {
+	if !strings.ContainsAny(f, "\\\n\r") {
+		return f
+	}
+	f = strings.ReplaceAll(f, "\\", "\\\\")
+	f = strings.ReplaceAll(f, "\n", "\\n")
+	f = strings.ReplaceAll(f, "\r", "\\r")
+	return f
+}
+
+func unescapePath(f string) string {
+	var b strings.Builder
+	for i := 0; i < len(f); i++ {
+		if f[i] == '\\' && i+1 < len(f) {
+			switch f[i+1] {
+			case '\\':
+				b.WriteByte('\\')
+				i++
+				continue
+			case 'n':
+				b.WriteByte('\n')
+				i++
+				continue
+			case 'r':
+				b.WriteByte('\r')
+				i++
+				continue
+			}
+		}
+		b.WriteByte(f[i])
+	}
+	return b.String()
 }
 
 func getNumFormatString(n uint, msg string) string {
@@ -150,10 +200,6 @@ func getNumFormatString(n uint, msg string) string {
 	return s
 }
 
-func printNumFormatString(n uint, msg string) {
-	fmt.Println(getNumFormatString(n, msg))
-}
-
 func assert(c bool) {
 	kassert(c, "Assert failed")
 }
@@ -164,7 +210,7 @@ func kassert(c bool, err interface{}) {
 	}
 }
 
-func panicFileType(f string, how string, t fileType) {
+func panicFileType(f string, how string, t FileType) {
 	if len(f) != 0 {
 		panic(fmt.Sprintf("%s has %s file type %d", f, how, t))
 	} else {
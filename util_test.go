@@ -1,4 +1,4 @@
-package main
+package dirhash
 
 import (
 	"fmt"
@@ -20,7 +20,7 @@ func Test_canonicalizePath(t *testing.T) {
 		{"/root/../dev", "/dev"},
 	}
 	for _, x := range pathList {
-		if s, err := canonicalizePath(x.i); err != nil || s != x.o {
+		if s, err := canonicalizePath(hostFS{}, x.i); err != nil || s != x.o {
 			t.Error(x)
 		}
 	}
@@ -54,12 +54,12 @@ var (
 
 func Test_getRawFileType(t *testing.T) {
 	for _, f := range dirList {
-		if ret, err := getRawFileType(f); ret != typeDir || err != nil {
+		if ret, err := getRawFileType(hostFS{}, f); ret != TypeDir || err != nil {
 			t.Error(f)
 		}
 	}
 	for _, f := range invalidList {
-		if ret, _ := getRawFileType(f); ret != typeInvalid {
+		if ret, _ := getRawFileType(hostFS{}, f); ret != TypeInvalid {
 			t.Error(f)
 		}
 	}
@@ -67,12 +67,12 @@ func Test_getRawFileType(t *testing.T) {
 
 func Test_getFileType(t *testing.T) {
 	for _, f := range dirList {
-		if ret, err := getFileType(f); ret != typeDir || err != nil {
+		if ret, err := getFileType(hostFS{}, f); ret != TypeDir || err != nil {
 			t.Error(f)
 		}
 	}
 	for _, f := range invalidList {
-		if ret, _ := getFileType(f); ret != typeInvalid {
+		if ret, _ := getFileType(hostFS{}, f); ret != TypeInvalid {
 			t.Error(f)
 		}
 	}
@@ -80,15 +80,15 @@ func Test_getFileType(t *testing.T) {
 
 func Test_getFileTypeString(t *testing.T) {
 	fileTypeList := []struct {
-		typ fileType
+		typ FileType
 		str string
 	}{
-		{typeDir, "directory"},
-		{typeReg, "regular file"},
-		{typeDevice, "device"},
-		{typeSymlink, "symlink"},
-		{typeUnsupported, "unsupported file"},
-		{typeInvalid, "invalid file"},
+		{TypeDir, "directory"},
+		{TypeReg, "regular file"},
+		{TypeDevice, "device"},
+		{TypeSymlink, "symlink"},
+		{TypeUnsupported, "unsupported file"},
+		{TypeInvalid, "invalid file"},
 	}
 	for _, x := range fileTypeList {
 		if getFileTypeString(x.typ) != x.str {
@@ -99,18 +99,18 @@ func Test_getFileTypeString(t *testing.T) {
 
 func Test_pathExists(t *testing.T) {
 	for _, f := range dirList {
-		if exists, err := pathExists(f); !exists || err != nil {
+		if exists, err := pathExists(hostFS{}, f); !exists || err != nil {
 			t.Error(f)
 		}
 	}
 	for _, f := range invalidList {
-		if exists, err := pathExists(f); exists || err == nil {
+		if exists, err := pathExists(hostFS{}, f); exists || err == nil {
 			t.Error(f)
 		}
 	}
 }
 
-func Test_isValidHexSum(t *testing.T) {
+func Test_IsValidHexSum(t *testing.T) {
 	validList := []string{
 		"00000000000000000000000000000000",
 		"11111111111111111111111111111111",
@@ -140,7 +140,7 @@ func Test_isValidHexSum(t *testing.T) {
 		"0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
 		"0x0123456789ABCDEFabcdef0123456789ABCDEFabcdef"}
 	for _, s := range validList {
-		if _, valid := isValidHexSum(s); !valid {
+		if _, valid := IsValidHexSum(s); !valid {
 			t.Error(s)
 		}
 	}
@@ -162,12 +162,35 @@ func Test_isValidHexSum(t *testing.T) {
 		"0",
 		""}
 	for _, s := range invalidList {
-		if _, valid := isValidHexSum(s); valid {
+		if _, valid := IsValidHexSum(s); valid {
 			t.Error(s)
 		}
 	}
 }
 
+func Test_EscapePath(t *testing.T) {
+	pathList := []struct {
+		input  string
+		output string
+	}{
+		{"", ""},
+		{"a", "a"},
+		{"a b", "a b"},
+		{"a\\b", "a\\\\b"},
+		{"a\nb", "a\\nb"},
+		{"a\rb", "a\\rb"},
+		{"\\\n\r", "\\\\\\n\\r"},
+	}
+	for _, x := range pathList {
+		if s := EscapePath(x.input); s != x.output {
+			t.Error(x, s)
+		}
+		if s := unescapePath(x.output); s != x.input {
+			t.Error(x, s)
+		}
+	}
+}
+
 func Test_getNumFormatString(t *testing.T) {
 	numFormatList := []struct {
 		n      uint
@@ -0,0 +1,238 @@
+package dirhash
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dirWalker walks directory tree same as fs.WalkDir, except that entries
+// are visited in lexical order of their paths if sorted, e.g. "a.b" before
+// "a/b", which is the order of sorting all walked paths.
+// Symlinks to directories are walked into if follow, and are passed to fn
+// as directories. A symlink to a directory being walked is a loop, which is
+// passed to loop instead of being walked into.
+// Only entries of directories being walked are held, so memory does not
+// grow with size of the tree.
+type dirWalker struct {
+	fsys   fileSystem
+	fn     fs.WalkDirFunc
+	sorted bool
+	follow bool
+	loop   func(f string)
+	dirs   map[fileKey]bool // directories being walked
+}
+
+// symlinkDirEntry is a symlink to directory walked as a directory.
+type symlinkDirEntry struct {
+	fs.DirEntry
+	info fs.FileInfo // target
+	loop bool
+}
+
+func (d *symlinkDirEntry) IsDir() bool {
+	return true
+}
+
+func (d *symlinkDirEntry) Type() fs.FileMode {
+	return fs.ModeDir
+}
+
+func (d *symlinkDirEntry) Info() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func newDirWalker(fsys fileSystem, fn fs.WalkDirFunc, sorted, follow bool, loop func(string)) *dirWalker {
+	return &dirWalker{
+		fsys:   fsys,
+		fn:     fn,
+		sorted: sorted,
+		follow: follow,
+		loop:   loop,
+		dirs:   make(map[fileKey]bool),
+	}
+}
+
+// walkDirSorted walks f with entries visited in lexical order of their paths.
+func walkDirSorted(fsys fileSystem, f string, fn fs.WalkDirFunc) error {
+	return newDirWalker(fsys, fn, true, false, nil).walk(f)
+}
+
+func (w *dirWalker) walk(f string) error {
+	info, err := w.fsys.lstat(f)
+	if err != nil {
+		err = w.fn(f, nil, err)
+	} else {
+		var d fs.DirEntry
+		d, err = w.resolve(f, fs.FileInfoToDirEntry(info))
+		if err == nil {
+			if err = w.fn(f, d, nil); err == nil && d.IsDir() {
+				err = w.walkDir(f, d)
+			}
+		}
+	}
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// resolve returns entry d of f as a directory if f is a symlink to be
+// walked into.
+func (w *dirWalker) resolve(f string, d fs.DirEntry) (fs.DirEntry, error) {
+	if !w.follow || d.Type()&fs.ModeSymlink == 0 {
+		return d, nil
+	}
+	info, err := w.fsys.stat(f)
+	if err != nil || !info.IsDir() {
+		return d, nil // broken symlink is reported by fn
+	}
+	st := getFileStat(info)
+	if st == nil {
+		return nil, fmt.Errorf("device and inode of %s unknown", f)
+	}
+	return &symlinkDirEntry{d, info, w.dirs[st.getKey()]}, nil
+}
+
+// walkDir walks entries of directory f, which has been visited.
+func (w *dirWalker) walkDir(f string, d fs.DirEntry) error {
+	if x, ok := d.(*symlinkDirEntry); ok && x.loop {
+		if w.loop != nil {
+			w.loop(f)
+		}
+		return nil
+	}
+	if w.follow {
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if st := getFileStat(info); st != nil {
+			k := st.getKey()
+			w.dirs[k] = true
+			defer delete(w.dirs, k)
+		}
+	}
+
+	l, err := w.fsys.readDir(f)
+	if err != nil {
+		// second call to report the error, same as fs.WalkDir
+		if err := w.fn(f, d, err); err != nil {
+			if err == filepath.SkipDir {
+				return nil
+			}
+			return err
+		}
+	}
+
+	// if sorted, directory is visited at its name, and its entries at
+	// name + "/", otherwise its entries are walked right after it
+	type walkEntry struct {
+		key string
+		d   fs.DirEntry
+	}
+	el := make([]walkEntry, 0, len(l))
+	for _, d := range l {
+		d, err := w.resolve(filepath.Join(f, d.Name()), d)
+		if err != nil {
+			return err
+		}
+		el = append(el, walkEntry{d.Name(), d})
+		if d.IsDir() && w.sorted {
+			el = append(el, walkEntry{d.Name() + "/", d})
+		}
+	}
+	if w.sorted {
+		sort.Slice(el, func(i, j int) bool {
+			return el[i].key < el[j].key
+		})
+	}
+
+	skip := make(map[string]bool) // directories skipped by fn
+	for _, e := range el {
+		x := filepath.Join(f, e.d.Name())
+		var err error
+		if !strings.HasSuffix(e.key, "/") {
+			err = w.fn(x, e.d, nil)
+			if err == filepath.SkipDir && e.d.IsDir() {
+				skip[e.key] = true
+				err = nil
+			} else if err == nil && e.d.IsDir() && !w.sorted {
+				err = w.walkDir(x, e.d)
+			}
+		} else if !skip[e.d.Name()] {
+			err = w.walkDir(x, e.d)
+		}
+		if err != nil {
+			if err == filepath.SkipDir {
+				break // skip the rest of f
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// walkLimit limits walk from root by depth and device.
+type walkLimit struct {
+	root     string
+	minDepth int
+	maxDepth int  // unlimited if 0
+	xdev     bool // do not cross device of root
+	dev      uint64
+}
+
+func (h *Hasher) newWalkLimit(f string) (*walkLimit, error) {
+	w := &walkLimit{
+		root:     f,
+		minDepth: h.opt.MinDepth,
+		maxDepth: h.opt.MaxDepth,
+		xdev:     h.opt.Xdev,
+	}
+	if w.xdev {
+		info, err := h.fs.lstat(f)
+		if err != nil {
+			return nil, err
+		}
+		st := getFileStat(info)
+		if st == nil {
+			return nil, fmt.Errorf("device of %s unknown", f)
+		}
+		w.dev = st.dev
+	}
+	return w, nil
+}
+
+// getDepth returns depth of walked entry f, where root is 0.
+func (w *walkLimit) getDepth(f string) int {
+	if f == w.root {
+		return 0
+	}
+	return strings.Count(strings.TrimPrefix(f[len(w.root):], "/"), "/") + 1
+}
+
+// isMountPoint returns true if walked entry f is a directory on device
+// other than root.
+func (w *walkLimit) isMountPoint(f string, d fs.DirEntry) (bool, error) {
+	if !w.xdev || !d.IsDir() || f == w.root {
+		return false, nil
+	}
+	info, err := d.Info()
+	if err != nil {
+		return false, err
+	}
+	st := getFileStat(info)
+	return st == nil || st.dev != w.dev, nil
+}
+
+// testDepth returns true if walked entry f is visited, and filepath.SkipDir
+// if entries of directory f are not walked.
+func (w *walkLimit) testDepth(f string, d fs.DirEntry) (bool, error) {
+	depth := w.getDepth(f)
+	if w.maxDepth > 0 && depth >= w.maxDepth && d.IsDir() {
+		return depth >= w.minDepth, filepath.SkipDir
+	}
+	return depth >= w.minDepth, nil
+}
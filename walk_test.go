@@ -0,0 +1,188 @@
+package dirhash
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+func Test_walkDirSorted(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/b":     {},
+		"a/b.c/d": {},
+		"a.b/c":   {},
+		"a-c/x/y": {},
+		"a-c/x.z": {},
+		"a0":      {},
+		"b/a":     {},
+		"b/a.b":   {},
+	}
+	x := newIOFS(fsys)
+
+	var l1 []string
+	if err := x.walkDir("/", func(f string, d fs.DirEntry, err error) error {
+		l1 = append(l1, f)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// unsorted walk is the same as fs.WalkDir
+	var l0 []string
+	if err := newDirWalker(x, func(f string, d fs.DirEntry, err error) error {
+		l0 = append(l0, f)
+		return err
+	}, false, false, nil).walk("/"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(l0, l1) {
+		t.Error(l0, l1)
+	}
+	sort.Strings(l1)
+
+	var l2 []string
+	if err := walkDirSorted(x, "/", func(f string, d fs.DirEntry, err error) error {
+		l2 = append(l2, f)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(l1, l2) {
+		t.Error(l1, l2)
+	}
+
+	// entries of skipped directory are not walked
+	var l3 []string
+	if err := walkDirSorted(x, "/", func(f string, d fs.DirEntry, err error) error {
+		l3 = append(l3, f)
+		if f == "/a" || f == "/a-c/x.z" {
+			return filepath.SkipDir
+		}
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if l := []string{"/", "/a", "/a-c", "/a-c/x", "/a-c/x.z", "/a.b", "/a.b/c",
+		"/a0", "/b", "/b/a", "/b/a.b"}; !reflect.DeepEqual(l, l3) {
+		t.Error(l3)
+	}
+}
+
+func Test_walkLimit(t *testing.T) {
+	w := &walkLimit{root: "/a"}
+	depthList := []struct {
+		f string
+		n int
+	}{
+		{"/a", 0},
+		{"/a/b", 1},
+		{"/a/b/c", 2},
+	}
+	for _, x := range depthList {
+		if n := w.getDepth(x.f); n != x.n {
+			t.Error(x, n)
+		}
+	}
+	if n := (&walkLimit{root: "/"}).getDepth("/a/b"); n != 2 {
+		t.Error(n)
+	}
+
+	// device is unknown
+	h, err := NewHasher(Options{HashAlgo: SHA256, Xdev: true, FS: fstest.MapFS{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.newWalkLimit("/"); err == nil {
+		t.Error("device unknown")
+	}
+}
+
+func Test_HashInputDepth(t *testing.T) {
+	d := newTestTree(t)
+	optList := []struct {
+		opt Options
+		l   []string
+	}{
+		{Options{MaxDepth: 1}, []string{"l", "z"}},
+		{Options{MaxDepth: 2}, []string{"a/x", "l", "z"}},
+		{Options{MinDepth: 2}, []string{"a/b/y", "a/x"}},
+		{Options{MinDepth: 2, MaxDepth: 2}, []string{"a/x"}},
+		{Options{MinDepth: 2, MaxDepth: 2, Squash: true}, []string{"a/b", "a/x"}},
+		{Options{MinDepth: 4}, nil},
+	}
+	for _, x := range optList {
+		opt := x.opt
+		opt.HashAlgo = SHA256
+		opt.Sort = true
+		h, err := NewHasher(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var l []string
+		if _, err := h.WalkInput(context.Background(), d, func(e *Entry) error {
+			l = append(l, e.Path)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(l, x.l) {
+			t.Error(x.opt, l)
+		}
+	}
+}
+
+func Test_HashInputFollowDirSymlink(t *testing.T) {
+	d := newTestTree(t)
+	if err := os.Symlink("a", filepath.Join(d, "la")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("..", filepath.Join(d, "a/b/up")); err != nil {
+		t.Fatal(err)
+	}
+	optList := []struct {
+		opt  Options
+		l    []string
+		loop []string
+	}{
+		{Options{}, []string{"a/b/up", "a/b/y", "a/x", "l", "la", "z"}, []string{}},
+		{Options{FollowDirSymlink: true},
+			[]string{"a/b/up", "a/b/y", "a/x", "l", "la", "la/b/up", "la/b/y", "la/x", "z"},
+			[]string{"a/b/up", "la/b/up"}},
+		{Options{FollowDirSymlink: true, Jobs: 4},
+			[]string{"a/b/up", "a/b/y", "a/x", "l", "la", "la/b/up", "la/b/y", "la/x", "z"},
+			[]string{"a/b/up", "la/b/up"}},
+		{Options{FollowDirSymlink: true, MaxDepth: 2},
+			[]string{"a/x", "l", "la", "la/x", "z"}, []string{}},
+		{Options{FollowDirSymlink: true, Exclude: []string{"la"}},
+			[]string{"a/b/up", "a/b/y", "a/x", "l", "z"}, []string{"a/b/up"}},
+		{Options{FollowDirSymlink: true, IgnoreSymlink: true},
+			[]string{"a/b/y", "a/x", "z"}, []string{}},
+	}
+	for _, x := range optList {
+		opt := x.opt
+		opt.HashAlgo = SHA256
+		opt.Sort = true
+		h, err := NewHasher(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var l []string
+		res, err := h.WalkInput(context.Background(), d, func(e *Entry) error {
+			l = append(l, e.Path)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(l, x.l) {
+			t.Error(x.opt, l)
+		}
+		if !reflect.DeepEqual(res.Stat.SymlinkLoop, x.loop) {
+			t.Error(x.opt, res.Stat.SymlinkLoop)
+		}
+	}
+}